@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	controller "oiynlike/controllers"
+	"oiynlike/database"
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// runCommand выполняет подкоманду сервера (например, create-admin)
+func runCommand(args []string) error {
+	switch args[0] {
+	case "create-admin":
+		return createAdminCommand(args[1:])
+	case "reindex-search":
+		return reindexSearchCommand()
+	case "backfill-locations":
+		return backfillLocationsCommand()
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// createAdminCommand назначает роль ADMIN уже зарегистрированному пользователю:
+//
+//	oiynlike create-admin --email admin@example.com
+func createAdminCommand(args []string) error {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	email := flags.String("email", "", "email of the registered user to promote")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *email == "" {
+		return fmt.Errorf("--email is required")
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	userCollection := database.OpenCollection("users")
+	if userCollection == nil {
+		return fmt.Errorf("database connection error")
+	}
+
+	var user models.User
+	err := userCollection.FindOne(ctx, bson.M{"email": *email}).Decode(&user)
+	if err != nil {
+		return fmt.Errorf("user with email %s not found, sign up first: %v", *email, err)
+	}
+
+	_, err = helper.ChangeUserRole(ctx, user.UserId, helper.RoleAdmin, "cli", helper.RoleChangeSourceCLI)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("User %s is now %s\n", *email, helper.RoleAdmin)
+	return nil
+}
+
+// reindexSearchCommand перестраивает поисковый индекс карточек и антикафе:
+//
+//	oiynlike reindex-search
+func reindexSearchCommand() error {
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	indexed, err := controller.ReindexSearch(ctx)
+	if err != nil {
+		return fmt.Errorf("error reindexing search after %d documents: %v", indexed, err)
+	}
+
+	fmt.Printf("Indexed %d documents\n", indexed)
+	return nil
+}
+
+// backfillLocationsCommand заполняет точки GeoJSON антикафе по их строковым координатам:
+//
+//	oiynlike backfill-locations
+func backfillLocationsCommand() error {
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	updated, err := controller.BackfillAnticafeLocations(ctx)
+	if err != nil {
+		return fmt.Errorf("error backfilling locations after %d anticafes: %v", updated, err)
+	}
+
+	fmt.Printf("Updated %d anticafes\n", updated)
+	return nil
+}
@@ -2,14 +2,20 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"oiynlike/database"
+	helper "oiynlike/helpers"
 	"oiynlike/models"
+	"oiynlike/pagination"
+	"oiynlike/search"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var anticafeCollection *mongo.Collection = database.OpenCollection("anticafe")
@@ -23,6 +29,38 @@ func CreateAnticafe() gin.HandlerFunc {
 			return
 		}
 
+		// Владелец заведения может создавать только свои антикафе,
+		// администратор может назначить владельца явно
+		if anticafe.OwnerID == "" || !helper.HasPermission(c.GetString("user_type"), helper.PermManageAnyResource) {
+			anticafe.OwnerID = c.GetString("uid")
+		}
+
+		if err := helper.ValidateOpeningHours(anticafe.OpeningTime, anticafe.ClosingTime); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if anticafe.TimeZone != "" {
+			if _, err := helper.LoadTimeZone(anticafe.TimeZone); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if err := normalizeTables(anticafe.Tables, nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Точка для поиска поблизости строится по координатам, а не принимается от клиента
+		anticafe.Location = nil
+		if anticafe.Latitude != "" || anticafe.Longitude != "" {
+			location, err := helper.ParseCoordinates(anticafe.Latitude, anticafe.Longitude)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			anticafe.Location = location
+		}
+
 		anticafe.CreatedAt = time.Now()
 		anticafe.UpdatedAt = time.Now()
 
@@ -32,6 +70,9 @@ func CreateAnticafe() gin.HandlerFunc {
 			return
 		}
 
+		anticafe.ID = result.InsertedID.(primitive.ObjectID)
+		indexAnticafe(context.Background(), anticafe)
+
 		c.JSON(http.StatusCreated, gin.H{"msg": "Anticafe has created succesfully", "data": result.InsertedID})
 	}
 }
@@ -49,14 +90,26 @@ func UpdateAnticafe() gin.HandlerFunc {
 			return
 		}
 
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid anticafe ID format"})
+			return
+		}
+
 		// Поиск антикафе по ID в базе данных
 		var existingAnticafe models.AnticafeModel
-		err := anticafeCollection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&existingAnticafe)
+		err = anticafeCollection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&existingAnticafe)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Anticafe not found"})
 			return
 		}
 
+		// Изменять антикафе может только его владелец (или администратор)
+		if err := helper.MatchUserTypeToUid(c, existingAnticafe.OwnerID); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
 		// Привязка данных запроса к структуре антикафе
 		var updatedAnticafe models.AnticafeModel
 		if err := c.ShouldBindJSON(&updatedAnticafe); err != nil {
@@ -67,7 +120,7 @@ func UpdateAnticafe() gin.HandlerFunc {
 		// Обновление только тех полей, которые были переданы в запросе
 		updateFields := bson.M{}
 		if updatedAnticafe.Title != "" {
-			updateFields["name"] = updatedAnticafe.Title
+			updateFields["title"] = updatedAnticafe.Title
 		}
 		if updatedAnticafe.Rating != "" {
 			updateFields["rating"] = updatedAnticafe.Rating
@@ -75,6 +128,30 @@ func UpdateAnticafe() gin.HandlerFunc {
 		if updatedAnticafe.Address != "" {
 			updateFields["address"] = updatedAnticafe.Address
 		}
+		if updatedAnticafe.City != "" {
+			updateFields["city"] = updatedAnticafe.City
+		}
+		if updatedAnticafe.TimeZone != "" {
+			if _, err := helper.LoadTimeZone(updatedAnticafe.TimeZone); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateFields["timeZone"] = updatedAnticafe.TimeZone
+		}
+		// Часы работы проверяются вместе с прежним значением другой границы
+		if updatedAnticafe.OpeningTime != "" || updatedAnticafe.ClosingTime != "" {
+			opening, closing := existingAnticafe.OpeningTime, existingAnticafe.ClosingTime
+			if updatedAnticafe.OpeningTime != "" {
+				opening = updatedAnticafe.OpeningTime
+			}
+			if updatedAnticafe.ClosingTime != "" {
+				closing = updatedAnticafe.ClosingTime
+			}
+			if err := helper.ValidateOpeningHours(opening, closing); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
 		if updatedAnticafe.OpeningTime != "" {
 			updateFields["openingTime"] = updatedAnticafe.OpeningTime
 		}
@@ -87,6 +164,22 @@ func UpdateAnticafe() gin.HandlerFunc {
 		if updatedAnticafe.Description != "" {
 			updateFields["description"] = updatedAnticafe.Description
 		}
+		// Переданный список столов заменяет прежний; столы с предстоящими бронями удалять нельзя
+		if updatedAnticafe.Tables != nil {
+			if err := normalizeTables(updatedAnticafe.Tables, existingAnticafe.Tables); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := checkRemovedTables(context.Background(), updatedAnticafe.Tables, existingAnticafe.Tables); err != nil {
+				if errors.Is(err, ErrTableInUse) {
+					c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			updateFields["tables"] = updatedAnticafe.Tables
+		}
 		if len(updatedAnticafe.Photos) > 0 {
 			updateFields["photos"] = updatedAnticafe.Photos
 		}
@@ -96,62 +189,74 @@ func UpdateAnticafe() gin.HandlerFunc {
 		if updatedAnticafe.Longitude != "" {
 			updateFields["longitude"] = updatedAnticafe.Longitude
 		}
+		// Новая широта или долгота проверяются вместе с прежним значением другой координаты
+		if updatedAnticafe.Latitude != "" || updatedAnticafe.Longitude != "" {
+			latitude, longitude := existingAnticafe.Latitude, existingAnticafe.Longitude
+			if updatedAnticafe.Latitude != "" {
+				latitude = updatedAnticafe.Latitude
+			}
+			if updatedAnticafe.Longitude != "" {
+				longitude = updatedAnticafe.Longitude
+			}
+			location, err := helper.ParseCoordinates(latitude, longitude)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateFields["location"] = location
+		}
 
 		// Установка времени обновления
 		updateFields["updatedAt"] = time.Now()
 
 		// Выполнение частичного обновления антикафе в базе данных
-		_, err = anticafeCollection.UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{"$set": updateFields})
+		err = anticafeCollection.FindOneAndUpdate(context.Background(),
+			bson.M{"_id": objectID},
+			bson.M{"$set": updateFields},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&existingAnticafe)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating anticafe"})
 			return
 		}
 
+		indexAnticafe(context.Background(), existingAnticafe)
+
+		// Привязанные карточки показывают актуальные сведения об антикафе
+		if err := refreshGameCardVenues(context.Background(), existingAnticafe); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating anticafe game cards"})
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{"message": "Anticafe updated successfully"})
 	}
 }
 
 // admin
 
+// anticafeSorts — сортировки списка антикафе (параметр sort_by)
+var anticafeSorts = pagination.Sorts{
+	"created_at": {Field: "createdAt"},
+}
+
 func GetAllAnticafe() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Получение всех антикафе из базы данных
-		cursor, err := anticafeCollection.Find(context.Background(), bson.M{})
+		params, err := pagination.ParseParams(c, anticafeSorts, "created_at", true)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving anticafe"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		defer cursor.Close(context.Background())
 
-		// Преобразование результатов запроса в массив антикафе
-		var anticafeList []models.AnticafeModel
-		if err := cursor.All(context.Background(), &anticafeList); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding anticafe list", "detail": err.Error()})
+		// С поисковым запросом q антикафе упорядочиваются по релевантности
+		if q := c.Query("q"); q != "" {
+			page, err := search.FindRanked[models.AnticafeModel](context.Background(), search.Default(), search.IndexAnticafes, anticafeCollection, q, bson.M{}, params)
+			respondPage(c, page, err)
 			return
 		}
 
-		// Формирование ответа в заданном формате
-		response := struct {
-			Items []models.AnticafeModel `json:"items"`
-			Meta  struct {
-				Current  int `json:"current"`
-				Total    int `json:"total"`
-				PageSize int `json:"page_size"`
-			} `json:"meta"`
-		}{
-			Items: anticafeList,
-			Meta: struct {
-				Current  int `json:"current"`
-				Total    int `json:"total"`
-				PageSize int `json:"page_size"`
-			}{
-				Current:  1,                 // текущая страница (ваше значение)
-				Total:    len(anticafeList), // общее количество элементов
-				PageSize: 10,                // размер страницы (ваше значение)
-			},
-		}
-
-		c.JSON(http.StatusOK, response)
+		// Получение страницы антикафе из базы данных
+		page, err := pagination.Find[models.AnticafeModel](context.Background(), anticafeCollection, bson.M{}, params)
+		respondPage(c, page, err)
 	}
 }
 
@@ -168,9 +273,15 @@ func GetAnticafeByID() gin.HandlerFunc {
 			return
 		}
 
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid anticafe ID format"})
+			return
+		}
+
 		// Поиск антикафе по ID в базе данных
 		var anticafe models.AnticafeModel
-		err := anticafeCollection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&anticafe)
+		err = anticafeCollection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&anticafe)
 		if err != nil {
 			// Если антикафе с указанным ID не найдено, возвращаем ошибку 404
 			c.JSON(http.StatusNotFound, gin.H{"error": "Anticafe not found"})
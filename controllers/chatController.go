@@ -46,8 +46,17 @@ func GetUserChatsHandler() gin.HandlerFunc {
 }
 
 func CreateChatIfNeeded(c *gin.Context, gameCard *models.GameCard) error {
-	// Создаем чат, если число присоединенных пользователей равно максимальному числу игроков
-	if len(gameCard.MatchedPlayers) == gameCard.MinPlayers {
+	// Создаем чат, как только набралось минимальное число игроков
+	if len(gameCard.MatchedPlayers) >= gameCard.MinPlayers {
+		// После выхода и повторного набора игроков чат уже может существовать
+		count, err := chatsCollection.CountDocuments(c, bson.M{"gamecard_id": gameCard.ID})
+		if err != nil {
+			return fmt.Errorf("error checking chat: %v", err)
+		}
+		if count > 0 {
+			return nil
+		}
+
 		chat := models.Chat{
 			Title:      gameCard.Title,
 			GameCardID: gameCard.ID,
@@ -74,7 +83,7 @@ func CreateChatIfNeeded(c *gin.Context, gameCard *models.GameCard) error {
 		}
 
 		// Сохраняем чат в базе данных
-		err := createChat(c, &chat)
+		err = createChat(c, &chat)
 		if err != nil {
 			return fmt.Errorf("error creating chat: %v", err)
 		}
@@ -195,6 +204,11 @@ func SendMessageHandler() gin.HandlerFunc {
 			return
 		}
 
+		if chat.Closed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Chat is closed"})
+			return
+		}
+
 		newMessage := models.Message{
 			Sender: models.Sender{
 				FirstName: user.FirstName,
@@ -236,3 +250,50 @@ func SendMessageHandler() gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"msg": "Message sent successfully"})
 	}
 }
+
+// addChatMember добавляет игрока в чат карточки, если чат уже создан и игрока в нём нет
+func addChatMember(ctx context.Context, gameCardID primitive.ObjectID, player models.MatchedPlayer) error {
+	member := models.Sender{
+		FirstName: player.FirstName,
+		LastName:  player.LastName,
+		UserID:    player.UserID,
+		PhotoURL:  player.PhotoURL,
+	}
+	filter := bson.M{"gamecard_id": gameCardID, "members.user_id": bson.M{"$ne": player.UserID}}
+
+	_, err := chatsCollection.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"members": member}})
+	if err != nil {
+		return fmt.Errorf("error adding chat member: %v", err)
+	}
+	return nil
+}
+
+// removeChatMember удаляет пользователя из чата карточки, если чат создан
+func removeChatMember(ctx context.Context, gameCardID primitive.ObjectID, userID string) error {
+	filter := bson.M{"gamecard_id": gameCardID}
+
+	_, err := chatsCollection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"members": bson.M{"user_id": userID}}})
+	if err != nil {
+		return fmt.Errorf("error removing chat member: %v", err)
+	}
+	return nil
+}
+
+// systemSender — отправитель служебных сообщений в чатах карточек
+var systemSender = models.Sender{FirstName: "oiynlike", UserID: "system"}
+
+// postSystemMessage публикует служебное сообщение в чат карточки, если чат создан
+func postSystemMessage(ctx context.Context, gameCardID primitive.ObjectID, text string) error {
+	message := models.Message{
+		ID:        primitive.NewObjectID(),
+		Sender:    systemSender,
+		Content:   text,
+		CreatedAt: time.Now(),
+	}
+
+	_, err := chatsCollection.UpdateOne(ctx, bson.M{"gamecard_id": gameCardID}, bson.M{"$push": bson.M{"messages": message}})
+	if err != nil {
+		return fmt.Errorf("error posting system message: %v", err)
+	}
+	return nil
+}
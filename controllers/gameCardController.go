@@ -2,20 +2,22 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"strconv"
 	"time"
 
 	"oiynlike/database"
+	helper "oiynlike/helpers"
 	"oiynlike/models"
+	"oiynlike/pagination"
+	"oiynlike/search"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var gameCardCollection *mongo.Collection = database.OpenCollection("gamecards")
@@ -24,6 +26,48 @@ func validateGameCard(gameCard *models.GameCard) error {
 	if err := validate.Struct(gameCard); err != nil {
 		return err
 	}
+	if gameCard.Location != nil {
+		if err := helper.ValidateGeoPoint(gameCard.Location); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultGameDuration — длительность игры, если не указаны end_time и duration_minutes
+const defaultGameDuration = 2 * time.Hour
+
+// normalizeSchedule проверяет время проведения карточки: начало в будущем, окончание
+// после начала (из end_time или duration_minutes), часовой пояс IANA (по умолчанию — пояс города)
+func normalizeSchedule(gameCard *models.GameCard, now time.Time) error {
+	if gameCard.TimeZone == "" {
+		gameCard.TimeZone = helper.DefaultTimeZone(gameCard.City)
+	}
+	if _, err := helper.LoadTimeZone(gameCard.TimeZone); err != nil {
+		return err
+	}
+
+	if gameCard.ScheduledTime.IsZero() {
+		return fmt.Errorf("scheduled_time is required")
+	}
+	if !gameCard.ScheduledTime.After(now) {
+		return fmt.Errorf("scheduled_time must be in the future")
+	}
+
+	if gameCard.DurationMinutes < 0 {
+		return fmt.Errorf("duration_minutes must be positive")
+	}
+	if gameCard.EndTime.IsZero() {
+		duration := defaultGameDuration
+		if gameCard.DurationMinutes > 0 {
+			duration = time.Duration(gameCard.DurationMinutes) * time.Minute
+		}
+		gameCard.EndTime = gameCard.ScheduledTime.Add(duration)
+	}
+	if !gameCard.EndTime.After(gameCard.ScheduledTime) {
+		return fmt.Errorf("end_time must be after scheduled_time")
+	}
+
 	return nil
 }
 
@@ -32,7 +76,9 @@ func insertGameCard(ctx context.Context, gameCard models.GameCard) (primitive.Ob
 	if err != nil {
 		return primitive.NilObjectID, err
 	}
-	return result.InsertedID.(primitive.ObjectID), nil
+	gameCard.ID = result.InsertedID.(primitive.ObjectID)
+	indexGameCard(ctx, gameCard)
+	return gameCard.ID, nil
 }
 
 func getGameCardByID(ctx context.Context, id primitive.ObjectID) (models.GameCard, error) {
@@ -62,6 +108,25 @@ func CreateGameCard() gin.HandlerFunc {
 			return
 		}
 
+		if err := normalizeSchedule(&gameCard, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Сведения об антикафе берутся из базы, а не из запроса; стол бронируется отдельно
+		gameCard.Venue = nil
+		gameCard.TableID = primitive.NilObjectID
+		if !gameCard.AnticafeID.IsZero() {
+			if err := attachVenue(ctx, &gameCard); err != nil {
+				if isVenueError(err) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
 		user, err := GetUserByID(c, userIDString)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data"})
@@ -82,8 +147,27 @@ func CreateGameCard() gin.HandlerFunc {
 
 		gameCard.CreatedAt = time.Now()
 		gameCard.UpdatedAt = time.Now()
-		gameCard.Status = "moderation"
+		// Карточку можно сохранить черновиком, иначе она сразу уходит на модерацию
+		if gameCard.Status != models.GameCardStatusDraft {
+			gameCard.Status = models.GameCardStatusModeration
+		}
+		gameCard.StatusHistory = []models.StatusChange{{
+			To:        gameCard.Status,
+			Actor:     helper.StatusActorHost,
+			ChangedBy: userIDString,
+			ChangedAt: gameCard.CreatedAt,
+		}}
+		if gameCard.JoinPolicy == "" {
+			gameCard.JoinPolicy = models.JoinPolicyOpen
+		}
 		gameCard.MatchedPlayers = []models.MatchedPlayer{} // Пустой массив для начала
+		// Лист ожидания заполняют только сами игроки через JoinGameCard
+		gameCard.Waitlist = []models.MatchedPlayer{}
+		// К серии карточку привязывает только генерация повторений (occurrenceFromSeries)
+		gameCard.SeriesID = primitive.NilObjectID
+		gameCard.SeriesOverride = false
+		// Время кворума отмечает checkQuorum; значение из запроса подавило бы событие кворума
+		gameCard.QuorumReachedAt = time.Time{}
 
 		// Вставляем созданную GameCard в базу данных
 		insertedID, err := insertGameCard(ctx, gameCard)
@@ -104,15 +188,27 @@ func CreateGameCard() gin.HandlerFunc {
 	}
 }
 
-func buildSortOption(sort, key string) bson.D {
-	switch sort {
-	case "desc":
-		return bson.D{{Key: key, Value: -1}}
-	case "asc":
-		return bson.D{{Key: key, Value: 1}}
-	default:
-		return bson.D{{Key: key, Value: 1}} // По умолчанию сортируем по возрастанию
+// gameCardSorts — сортировки списков игровых карточек (параметр sort_by)
+var gameCardSorts = pagination.Sorts{
+	"created_at":     {Field: "created_at"},
+	"scheduled_time": {Field: "scheduled_time"},
+	"remaining_seats": {Field: "remaining_seats", Expr: bson.M{"$subtract": bson.A{
+		"$max_players",
+		bson.M{"$size": bson.M{"$ifNull": bson.A{"$matched_players", bson.A{}}}},
+	}}},
+}
+
+// respondPage отвечает страницей списка или ошибкой пагинации
+func respondPage[T any](c *gin.Context, page pagination.Page[T], err error) {
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
+	c.JSON(http.StatusOK, page)
 }
 
 func GetActiveGameCards() gin.HandlerFunc {
@@ -120,23 +216,42 @@ func GetActiveGameCards() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		// Извлекаем параметры пагинации
+		// С параметром near=lat,lng карточки ищутся поблизости и сортируются по расстоянию
+		var center *models.GeoPoint
+		sorts, defaultSort, defaultDesc := gameCardSorts, "created_at", true
+		if near := c.Query("near"); near != "" {
+			point, err := helper.ParseLatLng(near)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			center = point
+			sorts, defaultSort, defaultDesc = nearSorts(center), "distance", false
+		}
+
+		// Извлекаем параметры пагинации и сортировки (по умолчанию — новые первыми)
+		params, err := pagination.ParseParams(c, sorts, defaultSort, defaultDesc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		sort := c.Query("sort")
 		city := c.Query("city")
 		from := c.Query("from")
 		to := c.Query("to")
 		category := c.Query("category")
 
-		// Рассчитываем смещение для запроса
-
 		userID, _ := c.Get("uid")
 		currentUserID := fmt.Sprintf("%v", userID)
 
-		// Формируем фильтр для исключения карт текущего пользователя
+		// Формируем фильтр для исключения карт текущего пользователя.
+		// Показываются только открытые для набора карточки: отменённые, заполненные
+		// и прошедшие в выдачу не попадают.
+		scheduledFilter := bson.M{"$gt": time.Now()}
 		filter := bson.M{
-			"status":            "active",
+			"status":            models.GameCardStatusActive,
 			"host_user.user_id": bson.M{"$ne": currentUserID},
+			"scheduled_time":    scheduledFilter,
 		}
 
 		if city != "" {
@@ -147,6 +262,15 @@ func GetActiveGameCards() gin.HandlerFunc {
 			filter["category"] = category
 		}
 
+		if anticafeID := c.Query("anticafe_id"); anticafeID != "" {
+			objectID, err := primitive.ObjectIDFromHex(anticafeID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid anticafe ID format"})
+				return
+			}
+			filter["anticafe_id"] = objectID
+		}
+
 		if from != "" && to != "" {
 			fromTime, err := time.Parse(time.RFC3339, from)
 			if err != nil {
@@ -158,33 +282,37 @@ func GetActiveGameCards() gin.HandlerFunc {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date format"})
 				return
 			}
-			filter["scheduled_time"] = bson.M{"$gte": fromTime, "$lte": toTime}
-		}
-
-		if sort == "" {
-			sort = "desc"
+			scheduledFilter["$gte"] = fromTime
+			scheduledFilter["$lte"] = toTime
 		}
 
-		sortOption := buildSortOption(sort, "created_at")
+		if center != nil {
+			if c.Query("q") != "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "q and near cannot be used together"})
+				return
+			}
+			radius, err := parseNearRadius(c)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			filter["location"] = helper.WithinRadiusFilter(center, radius)
 
-		// Запрашиваем активные игровые карты с учетом пагинации
-		cursor, err := gameCardCollection.Find(ctx, filter, options.Find().SetSort(sortOption))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			page, err := pagination.Find[GameCardWithDistance](ctx, gameCardCollection, filter, params)
+			respondPage(c, page, err)
 			return
 		}
-		defer cursor.Close(ctx)
 
-		// Проходим по результатам запроса и добавляем их в слайс
-		var gameCards []models.GameCard
-		if err := cursor.All(ctx, &gameCards); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// С поисковым запросом q карточки упорядочиваются по релевантности
+		if q := c.Query("q"); q != "" {
+			page, err := search.FindRanked[models.GameCard](ctx, search.Default(), search.IndexGameCards, gameCardCollection, q, filter, params)
+			respondPage(c, page, err)
 			return
 		}
 
-		// Отправляем успешный ответ с активными gamecards
-		c.JSON(http.StatusOK, gameCards)
-
+		// Запрашиваем активные игровые карты с учетом пагинации
+		page, err := pagination.Find[models.GameCard](ctx, gameCardCollection, filter, params)
+		respondPage(c, page, err)
 	}
 }
 
@@ -200,18 +328,12 @@ func GetUserGameCards() gin.HandlerFunc {
 
 		// Извлекаем параметры фильтрации и пагинации
 		status := c.Query("status")
-		page, _ := strconv.Atoi(c.Query("page"))
-		limit, _ := strconv.Atoi(c.Query("limit"))
-		if page <= 0 {
-			page = 1
-		}
-		if limit <= 0 || limit > 100 {
-			limit = 10
+		params, err := pagination.ParseParams(c, gameCardSorts, "created_at", true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
-		// Рассчитываем смещение для запроса
-		offset := (page - 1) * limit
-
 		// Формируем фильтр по UserID и статусу
 		filter := bson.M{"host_user.user_id": currentUserID}
 		if status != "" {
@@ -219,25 +341,8 @@ func GetUserGameCards() gin.HandlerFunc {
 		}
 
 		// Запрашиваем игровые карты пользователя с учетом фильтрации и пагинации
-		cursor, err := gameCardCollection.Find(ctx, filter, options.Find().SetLimit(int64(limit)).SetSkip(int64(offset)))
-		if err != nil {
-			log.Println("Error querying database:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		defer cursor.Close(ctx)
-
-		// Проходим по результатам запроса и добавляем их в срез
-		var userGameCards []models.GameCard
-		if err := cursor.All(ctx, &userGameCards); err != nil {
-			log.Println("Error decoding results:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		// Отправляем успешный ответ с игровыми картами пользователя
-		c.JSON(http.StatusOK, userGameCards)
-		log.Println("Response sent:", userGameCards)
+		page, err := pagination.Find[models.GameCard](ctx, gameCardCollection, filter, params)
+		respondPage(c, page, err)
 	}
 }
 
@@ -251,17 +356,18 @@ func UpdateGameCard() gin.HandlerFunc {
 			return
 		}
 
-		// Получаем UserID из JWT токена
-		userID, _ := c.Get("uid")
-		currentUserID := fmt.Sprintf("%v", userID)
-
-		isHost, err := isUserHostOfGameCard(context.Background(), currentUserID, gameCardID)
+		gameCard, err := getGameCardByID(context.Background(), objectID)
 		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{"error": "GameCard not found"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking host status"})
 			return
 		}
 
-		if !isHost {
+		// Изменять карточку может только её хост (или администратор)
+		if err := helper.MatchUserTypeToUid(c, gameCard.HostUser.UserID); err != nil {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Only the host can update the gameCard"})
 			return
 		}
@@ -271,31 +377,169 @@ func UpdateGameCard() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 			return
 		}
+		// Статус меняется только переходами жизненного цикла
+		if updateData.Status != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Use PATCH /api/gamecards/:gameCardID/status to change the status"})
+			return
+		}
+		// Состав и лист ожидания меняются только атомарными операциями присоединения и выхода
+		updateData.MatchedPlayers = nil
+		updateData.Waitlist = nil
+		// Привязку к серии клиент не меняет; series_override выставляется ниже
+		updateData.SeriesID = primitive.NilObjectID
+		updateData.QuorumReachedAt = time.Time{}
+		if updateData.JoinPolicy != "" && updateData.JoinPolicy != models.JoinPolicyOpen && updateData.JoinPolicy != models.JoinPolicyApproval {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "join_policy must be open or approval"})
+			return
+		}
+		if updateData.Location != nil {
+			if err := helper.ValidateGeoPoint(updateData.Location); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		// Время проведения проверяется целиком: новое начало сохраняет прежнюю длительность
+		if !updateData.ScheduledTime.IsZero() || !updateData.EndTime.IsZero() || updateData.DurationMinutes != 0 || updateData.TimeZone != "" {
+			schedule := models.GameCard{
+				City:            gameCard.City,
+				ScheduledTime:   gameCard.ScheduledTime,
+				EndTime:         gameCard.EndTime,
+				DurationMinutes: updateData.DurationMinutes,
+				TimeZone:        gameCard.TimeZone,
+			}
+			if updateData.TimeZone != "" {
+				schedule.TimeZone = updateData.TimeZone
+			}
+			if !updateData.ScheduledTime.IsZero() {
+				if !gameCard.EndTime.IsZero() && updateData.EndTime.IsZero() && updateData.DurationMinutes == 0 {
+					schedule.EndTime = updateData.ScheduledTime.Add(gameCard.EndTime.Sub(gameCard.ScheduledTime))
+				}
+				schedule.ScheduledTime = updateData.ScheduledTime
+			}
+			if !updateData.EndTime.IsZero() {
+				schedule.EndTime = updateData.EndTime
+			} else if updateData.DurationMinutes != 0 {
+				schedule.EndTime = time.Time{}
+			}
+
+			if err := normalizeSchedule(&schedule, time.Now()); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateData.ScheduledTime = schedule.ScheduledTime
+			updateData.EndTime = schedule.EndTime
+			updateData.TimeZone = schedule.TimeZone
+		}
+
+		// Бронь стола сделана на прежние время и антикафе: её нужно снять до их изменения
+		if !gameCard.TableID.IsZero() {
+			if !updateData.ScheduledTime.IsZero() || (!updateData.AnticafeID.IsZero() && updateData.AnticafeID != gameCard.AnticafeID) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Release the reserved table before changing the time or anticafe"})
+				return
+			}
+		}
+		updateData.TableID = primitive.NilObjectID
+
+		// Новое антикафе или новое время проверяются по часам работы антикафе
+		updateData.Venue = nil
+		if !updateData.AnticafeID.IsZero() || (!gameCard.AnticafeID.IsZero() && !updateData.ScheduledTime.IsZero()) {
+			venueCard := models.GameCard{
+				AnticafeID:    gameCard.AnticafeID,
+				ScheduledTime: gameCard.ScheduledTime,
+				EndTime:       gameCard.EndTime,
+				TimeZone:      gameCard.TimeZone,
+				Location:      gameCard.Location,
+			}
+			// Точка прежнего антикафе не переносится на новое: без своего location карточка получит точку нового
+			if !updateData.AnticafeID.IsZero() && updateData.AnticafeID != gameCard.AnticafeID {
+				venueCard.AnticafeID = updateData.AnticafeID
+				venueCard.Location = nil
+			}
+			if !updateData.ScheduledTime.IsZero() {
+				venueCard.ScheduledTime = updateData.ScheduledTime
+				venueCard.EndTime = updateData.EndTime
+				venueCard.TimeZone = updateData.TimeZone
+			}
+			if updateData.Location != nil {
+				venueCard.Location = updateData.Location
+			}
+
+			if err := attachVenue(context.Background(), &venueCard); err != nil {
+				if isVenueError(err) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			updateData.AnticafeID = venueCard.AnticafeID
+			updateData.Venue = venueCard.Venue
+			updateData.Location = venueCard.Location
+		}
+
+		// Лимиты игроков проверяются вместе с текущими значениями карточки
+		minPlayers, maxPlayers := gameCard.MinPlayers, gameCard.MaxPlayers
+		if updateData.MinPlayers != 0 {
+			minPlayers = updateData.MinPlayers
+		}
+		if updateData.MaxPlayers != 0 {
+			maxPlayers = updateData.MaxPlayers
+		}
+		if minPlayers < 0 || maxPlayers < 0 || minPlayers > maxPlayers {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_players must be positive and not greater than max_players"})
+			return
+		}
+		// За забронированным столом должно хватать мест для max_players
+		if !gameCard.TableID.IsZero() && updateData.MaxPlayers != 0 {
+			var anticafe models.AnticafeModel
+			err := anticafeCollection.FindOne(context.Background(), bson.M{"_id": gameCard.AnticafeID}).Decode(&anticafe)
+			if err != nil && err != mongo.ErrNoDocuments {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if table, ok := findTable(anticafe, gameCard.TableID); ok && table.Seats < updateData.MaxPlayers {
+				c.JSON(http.StatusConflict, gin.H{"error": ErrTableTooSmall.Error()})
+				return
+			}
+		}
+
+		// Повторение серии, изменённое отдельно, больше не обновляется правками серии
+		updateData.SeriesOverride = !gameCard.SeriesID.IsZero()
+
+		// Неизменившееся начало не записывается, чтобы не сбросить отправленное напоминание
+		if updateData.ScheduledTime.Equal(gameCard.ScheduledTime) {
+			updateData.ScheduledTime = time.Time{}
+		}
 
 		// Вызовите функцию обновления gameCard
 		err = updateGameCard(context.Background(), objectID, updateData)
+		if errors.Is(err, ErrGameCardNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, ErrRosterExceedsMax) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating gameCard"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"msg": "GameCard updated successfully"})
-	}
-}
-
-func isUserHostOfGameCard(ctx context.Context, userID interface{}, gameCardID string) (bool, error) {
-	// Преобразуем gameCardID в ObjectID
-	objectID, err := primitive.ObjectIDFromHex(gameCardID)
-	if err != nil {
-		return false, fmt.Errorf("invalid gameCardID format")
-	}
+		// Новые лимиты могут освободить места для листа ожидания, закрыть набор или дать кворум
+		if updateData.MinPlayers != 0 || updateData.MaxPlayers != 0 {
+			updatedCard, err := getGameCardByID(c, objectID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving gameCard data"})
+				return
+			}
+			if err := syncRoster(c, &updatedCard); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
 
-	// Найдем gameCard в базе данных по ID и проверим, является ли пользователь хостом
-	filter := bson.M{"_id": objectID, "host_user.user_id": userID}
-	count, err := gameCardCollection.CountDocuments(ctx, filter)
-	if err != nil {
-		return false, fmt.Errorf("error checking host status: %v", err)
+		c.JSON(http.StatusOK, gin.H{"msg": "GameCard updated successfully"})
 	}
-
-	return count > 0, nil
 }
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GameCardViewer — отношение текущего пользователя к карточке
+type GameCardViewer struct {
+	IsHost           bool               `json:"is_host"`
+	IsPlayer         bool               `json:"is_player"`
+	IsWaitlisted     bool               `json:"is_waitlisted"`
+	WaitlistPosition int                `json:"waitlist_position,omitempty"`
+	IsPending        bool               `json:"is_pending"`
+	JoinRequestID    primitive.ObjectID `json:"join_request_id,omitempty"`
+	RemainingSeats   int                `json:"remaining_seats"`
+	ChatID           primitive.ObjectID `json:"chat_id,omitempty"`
+}
+
+// publicGameCardStatuses — статусы, в которых карточку видят все пользователи.
+// Черновики, карточки на модерации и отклонённые видны только хосту и модераторам.
+var publicGameCardStatuses = map[string]bool{
+	models.GameCardStatusActive:    true,
+	models.GameCardStatusFull:      true,
+	models.GameCardStatusStarted:   true,
+	models.GameCardStatusCompleted: true,
+	models.GameCardStatusCancelled: true,
+}
+
+// gameCardViewer собирает поля карточки, зависящие от пользователя userID
+func gameCardViewer(ctx context.Context, gameCard models.GameCard, userID string) (GameCardViewer, error) {
+	viewer := GameCardViewer{IsHost: gameCard.HostUser.UserID == userID}
+
+	viewer.RemainingSeats = gameCard.MaxPlayers - len(gameCard.MatchedPlayers)
+	if viewer.RemainingSeats < 0 {
+		viewer.RemainingSeats = 0
+	}
+
+	for _, player := range gameCard.MatchedPlayers {
+		if player.UserID == userID {
+			viewer.IsPlayer = true
+		}
+	}
+	for i, player := range gameCard.Waitlist {
+		if player.UserID == userID {
+			viewer.IsWaitlisted = true
+			viewer.WaitlistPosition = i + 1
+		}
+	}
+
+	var request models.JoinRequest
+	err := joinRequestCollection.FindOne(ctx, bson.M{
+		"gamecard_id":    gameCard.ID,
+		"player.user_id": userID,
+		"status":         models.JoinRequestPending,
+	}).Decode(&request)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return viewer, fmt.Errorf("error retrieving join request: %v", err)
+	}
+	if err == nil {
+		viewer.IsPending = true
+		viewer.JoinRequestID = request.ID
+	}
+
+	// ID чата показываем только его участникам
+	var chat models.Chat
+	err = chatsCollection.FindOne(ctx,
+		bson.M{"gamecard_id": gameCard.ID, "members.user_id": userID},
+		options.FindOne().SetProjection(bson.M{"_id": 1}),
+	).Decode(&chat)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return viewer, fmt.Errorf("error retrieving chat: %v", err)
+	}
+	if err == nil {
+		viewer.ChatID = chat.ID
+	}
+
+	return viewer, nil
+}
+
+// GetGameCard возвращает карточку вместе с полями, зависящими от текущего пользователя
+func GetGameCard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, _ := c.Get("uid")
+		userIDString := fmt.Sprintf("%v", userID)
+
+		objectID, err := primitive.ObjectIDFromHex(c.Param("gameCardID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Id is incorrect"})
+			return
+		}
+
+		gameCard, err := getGameCardByID(ctx, objectID)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "GameCard not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving gameCard data"})
+			return
+		}
+
+		if !publicGameCardStatuses[gameCard.Status] &&
+			helper.MatchUserTypeToUid(c, gameCard.HostUser.UserID) != nil &&
+			!helper.HasRolePermission(c, helper.PermGameCardsModerate) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "GameCard not found"})
+			return
+		}
+
+		viewer, err := gameCardViewer(ctx, gameCard, userIDString)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": gameCard, "viewer": viewer})
+	}
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// StartDueGameCards переводит в "started" карточки, время начала которых наступило
+func StartDueGameCards(ctx context.Context, now time.Time) (int, error) {
+	filter := bson.M{
+		"status":         bson.M{"$in": bson.A{models.GameCardStatusActive, models.GameCardStatusFull}},
+		"scheduled_time": bson.M{"$lte": now},
+	}
+	return transitionDueGameCards(ctx, filter, models.GameCardStatusStarted, "scheduled time reached")
+}
+
+// CompleteFinishedGameCards переводит в "completed" начавшиеся карточки, время окончания
+// которых прошло. У старых карточек без end_time окончание — начало плюс длительность по умолчанию.
+func CompleteFinishedGameCards(ctx context.Context, now time.Time) (int, error) {
+	filter := bson.M{
+		"status": models.GameCardStatusStarted,
+		"$or": bson.A{
+			bson.M{"end_time": bson.M{"$lte": now}},
+			bson.M{"end_time": bson.M{"$exists": false}, "scheduled_time": bson.M{"$lte": now.Add(-defaultGameDuration)}},
+		},
+	}
+	return transitionDueGameCards(ctx, filter, models.GameCardStatusCompleted, "end time reached")
+}
+
+// transitionDueGameCards выполняет системный переход для всех карточек, подходящих под filter
+func transitionDueGameCards(ctx context.Context, filter bson.M, to string, reason string) (int, error) {
+	cursor, err := gameCardCollection.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var gameCards []models.GameCard
+	if err := cursor.All(ctx, &gameCards); err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, gameCard := range gameCards {
+		err := transitionGameCardStatus(ctx, &gameCard, to, helper.StatusActorSystem, "", reason, nil)
+		if errors.Is(err, ErrGameCardStatusChanged) {
+			continue
+		}
+		if err != nil {
+			return changed, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// RunGameCardLifecycle начинает и завершает карточки по их времени проведения
+func RunGameCardLifecycle(ctx context.Context) error {
+	now := time.Now()
+	if _, err := StartDueGameCards(ctx, now); err != nil {
+		return err
+	}
+	_, err := CompleteFinishedGameCards(ctx, now)
+	return err
+}
@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrGameCardStatusChanged возвращается, если статус карточки изменили параллельно
+var ErrGameCardStatusChanged = errors.New("gameCard status was changed concurrently")
+
+type CancelGameCardRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type StatusChangeRequest struct {
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// transitionGameCardStatus переводит карточку в статус to и дописывает переход в историю.
+// Переход проверяется по жизненному циклу, а запись выполняется условным обновлением:
+// статус в базе должен совпадать с gameCard.Status и выполняться condition (если задано).
+func transitionGameCardStatus(ctx context.Context, gameCard *models.GameCard, to, actor, changedBy, reason string, condition bson.M) error {
+	from := gameCard.Status
+	if err := helper.CheckGameCardTransition(from, to, actor); err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": gameCard.ID, "status": from}
+	for key, value := range condition {
+		filter[key] = value
+	}
+
+	change := models.StatusChange{
+		From:      from,
+		To:        to,
+		Actor:     actor,
+		ChangedBy: changedBy,
+		Reason:    reason,
+		ChangedAt: time.Now(),
+	}
+	update := bson.M{
+		"$set":  bson.M{"status": to, "updated_at": change.ChangedAt},
+		"$push": bson.M{"status_history": change},
+	}
+
+	result, err := gameCardCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("error updating gameCard status: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrGameCardStatusChanged
+	}
+
+	gameCard.Status = to
+	gameCard.StatusHistory = append(gameCard.StatusHistory, change)
+
+	// Отменённая или отклонённая игра освобождает забронированный стол
+	if to == models.GameCardStatusCancelled || to == models.GameCardStatusRejected {
+		return releaseGameCardTable(ctx, gameCard)
+	}
+	return nil
+}
+
+// statusActor определяет, от чьего имени пользователь запроса меняет статус карточки:
+// хост действует как хост, модератор или администратор на чужой карточке — как модератор
+func statusActor(c *gin.Context, gameCard models.GameCard) string {
+	if c.GetString("uid") != gameCard.HostUser.UserID && helper.HasRolePermission(c, helper.PermGameCardsModerate) {
+		return helper.StatusActorModerator
+	}
+	return helper.StatusActorHost
+}
+
+// respondStatusTransitionError отвечает клиенту в зависимости от типа ошибки перехода
+func respondStatusTransitionError(c *gin.Context, err error) {
+	var invalidTransition *helper.InvalidTransitionError
+	switch {
+	case errors.As(err, &invalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, helper.ErrStatusTransitionForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrGameCardStatusChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ChangeGameCardStatus — переход статуса карточки её хостом (отправить на модерацию,
+// начать, завершить или отменить игру). Администратор меняет статус чужой карточки
+// с правами модератора.
+func ChangeGameCardStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, _ := c.Get("uid")
+		userIDString := fmt.Sprintf("%v", userID)
+
+		objectID, err := primitive.ObjectIDFromHex(c.Param("gameCardID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Id is incorrect"})
+			return
+		}
+
+		var request StatusChangeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !helper.IsValidGameCardStatus(request.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown status"})
+			return
+		}
+
+		gameCard, err := getGameCardByID(ctx, objectID)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "GameCard not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving gameCard data"})
+			return
+		}
+
+		// Менять статус может только хост карточки (или администратор)
+		if err := helper.MatchUserTypeToUid(c, gameCard.HostUser.UserID); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the host can change the gameCard status"})
+			return
+		}
+
+		err = transitionGameCardStatus(ctx, &gameCard, request.Status, statusActor(c, gameCard), userIDString, request.Reason, nil)
+		if err != nil {
+			respondStatusTransitionError(c, err)
+			return
+		}
+
+		if gameCard.Status == models.GameCardStatusCancelled {
+			if err := onGameCardCancelled(ctx, gameCard, request.Reason); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "GameCard status updated successfully", "data": gameCard})
+	}
+}
+
+// onGameCardCancelled закрывает хвосты отменённой карточки: отклоняет ожидающие
+// заявки, пишет служебное сообщение в чат и уведомляет игроков и лист ожидания
+func onGameCardCancelled(ctx context.Context, gameCard models.GameCard, reason string) error {
+	_, err := joinRequestCollection.UpdateMany(ctx,
+		bson.M{"gamecard_id": gameCard.ID, "status": models.JoinRequestPending},
+		bson.M{"$set": bson.M{"status": models.JoinRequestRejected, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("error rejecting join requests: %v", err)
+	}
+
+	text := fmt.Sprintf("Игра «%s» отменена", gameCard.Title)
+	if reason != "" {
+		text = fmt.Sprintf("%s. Причина: %s", text, reason)
+	}
+
+	if err := postSystemMessage(ctx, gameCard.ID, text); err != nil {
+		return err
+	}
+
+	recipients := make([]string, 0, len(gameCard.MatchedPlayers)+len(gameCard.Waitlist))
+	for _, player := range gameCard.MatchedPlayers {
+		recipients = append(recipients, player.UserID)
+	}
+	for _, player := range gameCard.Waitlist {
+		recipients = append(recipients, player.UserID)
+	}
+
+	return helper.NotifyUsers(ctx, recipients, models.Notification{
+		Type:       models.NotificationGameCardCancelled,
+		Title:      "Игра отменена",
+		Body:       text,
+		GameCardID: gameCard.ID,
+	})
+}
+
+// CancelGameCard — отмена карточки хостом с обязательной причиной
+func CancelGameCard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, _ := c.Get("uid")
+		userIDString := fmt.Sprintf("%v", userID)
+
+		objectID, err := primitive.ObjectIDFromHex(c.Param("gameCardID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Id is incorrect"})
+			return
+		}
+
+		var request CancelGameCardRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		gameCard, err := getGameCardByID(ctx, objectID)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "GameCard not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving gameCard data"})
+			return
+		}
+
+		// Отменить карточку может только её хост (или администратор)
+		if err := helper.MatchUserTypeToUid(c, gameCard.HostUser.UserID); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the host can cancel the gameCard"})
+			return
+		}
+
+		err = transitionGameCardStatus(ctx, &gameCard, models.GameCardStatusCancelled, statusActor(c, gameCard), userIDString, request.Reason, nil)
+		if err != nil {
+			respondStatusTransitionError(c, err)
+			return
+		}
+
+		if err := onGameCardCancelled(ctx, gameCard, request.Reason); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "GameCard cancelled successfully"})
+	}
+}
+
+// legacyGameCardStatusInactive — статус заполненной карточки до появления жизненного цикла
+const legacyGameCardStatusInactive = "inactive"
+
+// MigrateInactiveGameCards переводит карточки со старым статусом "inactive" в "full",
+// а если с тех пор освободились места — в "active". Повторный запуск ничего не меняет.
+func MigrateInactiveGameCards(ctx context.Context) (int64, error) {
+	roster := bson.M{"$size": bson.M{"$ifNull": bson.A{"$matched_players", bson.A{}}}}
+	targets := []struct {
+		status string
+		expr   bson.M
+	}{
+		{models.GameCardStatusFull, bson.M{"$gte": bson.A{roster, "$max_players"}}},
+		{models.GameCardStatusActive, bson.M{"$lt": bson.A{roster, "$max_players"}}},
+	}
+
+	var migrated int64
+	for _, target := range targets {
+		now := time.Now()
+		result, err := gameCardCollection.UpdateMany(ctx,
+			bson.M{"status": legacyGameCardStatusInactive, "$expr": target.expr},
+			bson.M{
+				"$set": bson.M{"status": target.status, "updated_at": now},
+				"$push": bson.M{"status_history": models.StatusChange{
+					From:      legacyGameCardStatusInactive,
+					To:        target.status,
+					Actor:     helper.StatusActorSystem,
+					Reason:    "status migration",
+					ChangedAt: now,
+				}},
+			},
+		)
+		if err != nil {
+			return migrated, fmt.Errorf("error migrating inactive gameCards: %v", err)
+		}
+		migrated += result.ModifiedCount
+	}
+	return migrated, nil
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStatusActor(t *testing.T) {
+	gameCard := models.GameCard{HostUser: models.HostUser{UserID: "host"}}
+
+	tests := []struct {
+		name     string
+		uid      string
+		userType string
+		mfa      bool
+		actor    string
+	}{
+		{"host", "host", helper.RoleUser, false, helper.StatusActorHost},
+		{"admin who hosts the card", "host", helper.RoleAdmin, true, helper.StatusActorHost},
+		{"admin on another card", "admin", helper.RoleAdmin, true, helper.StatusActorModerator},
+		{"moderator on another card", "moderator", helper.RoleModerator, true, helper.StatusActorModerator},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Set("uid", tt.uid)
+			c.Set("user_type", tt.userType)
+			c.Set("mfa", tt.mfa)
+
+			if actor := statusActor(c, gameCard); actor != tt.actor {
+				t.Fatalf("expected %s, got %s", tt.actor, actor)
+			}
+		})
+	}
+}
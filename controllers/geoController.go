@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+	"oiynlike/pagination"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// defaultNearRadiusKm — радиус поиска поблизости, если radius_km не указан
+	defaultNearRadiusKm = 5.0
+	// maxNearRadiusKm ограничивает радиус поиска поблизости
+	maxNearRadiusKm = 100.0
+)
+
+// GameCardWithDistance — карточка в выдаче поиска поблизости с расстоянием до неё
+type GameCardWithDistance struct {
+	models.GameCard `bson:",inline"`
+	DistanceKm      float64 `json:"distance_km" bson:"distance_km"`
+}
+
+// nearSorts — единственная сортировка выдачи поблизости: по расстоянию до center
+func nearSorts(center *models.GeoPoint) pagination.Sorts {
+	return pagination.Sorts{
+		"distance": {Field: "distance_km", Expr: helper.DistanceKmExpr(center, "location")},
+	}
+}
+
+// parseNearRadius читает radius_km; по умолчанию defaultNearRadiusKm
+func parseNearRadius(c *gin.Context) (float64, error) {
+	value := c.Query("radius_km")
+	if value == "" {
+		return defaultNearRadiusKm, nil
+	}
+	radius, err := strconv.ParseFloat(value, 64)
+	if err != nil || radius <= 0 || radius > maxNearRadiusKm {
+		return 0, fmt.Errorf("radius_km must be a number between 0 and %v", maxNearRadiusKm)
+	}
+	return radius, nil
+}
+
+// EnsureGeoIndexes создаёт индексы 2dsphere для поиска карточек и антикафе поблизости
+func EnsureGeoIndexes(ctx context.Context) error {
+	for _, collection := range []*mongo.Collection{gameCardCollection, anticafeCollection} {
+		_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "location", Value: "2dsphere"}},
+		})
+		if err != nil {
+			return fmt.Errorf("error creating 2dsphere index on %s: %v", collection.Name(), err)
+		}
+	}
+	return nil
+}
+
+// BackfillAnticafeLocations заполняет location антикафе, созданных до появления поиска
+// поблизости, по их строковым координатам. Антикафе с некорректными координатами пропускаются.
+func BackfillAnticafeLocations(ctx context.Context) (int, error) {
+	cursor, err := anticafeCollection.Find(ctx, bson.M{
+		"location":  bson.M{"$exists": false},
+		"latitude":  bson.M{"$nin": bson.A{"", nil}},
+		"longitude": bson.M{"$nin": bson.A{"", nil}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	updated := 0
+	for cursor.Next(ctx) {
+		var anticafe models.AnticafeModel
+		if err := cursor.Decode(&anticafe); err != nil {
+			return updated, err
+		}
+
+		location, err := helper.ParseCoordinates(anticafe.Latitude, anticafe.Longitude)
+		if err != nil {
+			log.Printf("Skipping anticafe %s: %v", anticafe.ID.Hex(), err)
+			continue
+		}
+
+		_, err = anticafeCollection.UpdateOne(ctx, bson.M{"_id": anticafe.ID}, bson.M{"$set": bson.M{"location": location}})
+		if err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, cursor.Err()
+}
@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"oiynlike/database"
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+	"oiynlike/pagination"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var joinRequestCollection *mongo.Collection = database.OpenCollection("join_requests")
+
+var (
+	ErrJoinRequestNotFound = errors.New("join request not found")
+	ErrJoinRequestDecided  = errors.New("join request is already decided")
+)
+
+// gameCardRequiresApproval сообщает, что к карточке присоединяются только через заявку хосту
+func gameCardRequiresApproval(ctx context.Context, gameCardID primitive.ObjectID) (bool, error) {
+	gameCard, err := getGameCardByID(ctx, gameCardID)
+	if err == mongo.ErrNoDocuments {
+		return false, ErrGameCardNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("error retrieving gameCard data: %v", err)
+	}
+	return gameCard.JoinPolicy == models.JoinPolicyApproval, nil
+}
+
+// createJoinRequest создаёт заявку на участие. Повторная заявка при уже
+// ожидающей возвращает существующую, а не создаёт дубликат.
+func createJoinRequest(c *gin.Context, userID string, gameCardID primitive.ObjectID) (models.JoinRequest, error) {
+	var request models.JoinRequest
+
+	gameCard, err := getGameCardByID(c, gameCardID)
+	if err == mongo.ErrNoDocuments {
+		return request, ErrGameCardNotFound
+	}
+	if err != nil {
+		return request, fmt.Errorf("error retrieving gameCard data: %v", err)
+	}
+	if gameCard.Status != models.GameCardStatusActive {
+		return request, ErrGameCardNotActive
+	}
+	if gameCard.HostUser.UserID == userID {
+		return request, ErrHostCannotJoin
+	}
+	for _, player := range gameCard.MatchedPlayers {
+		if player.UserID == userID {
+			return request, ErrAlreadyJoined
+		}
+	}
+
+	user, err := GetUserByID(c, userID)
+	if err != nil {
+		return request, fmt.Errorf("error retrieving user data: %v", err)
+	}
+
+	now := time.Now()
+	filter := bson.M{
+		"gamecard_id":    gameCardID,
+		"player.user_id": userID,
+		"status":         models.JoinRequestPending,
+	}
+	update := bson.M{"$setOnInsert": bson.M{
+		"gamecard_id": gameCardID,
+		"player":      matchedPlayerFromUser(user),
+		"status":      models.JoinRequestPending,
+		"created_at":  now,
+		"updated_at":  now,
+	}}
+
+	err = joinRequestCollection.FindOneAndUpdate(c, filter, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&request)
+	if err != nil {
+		return request, fmt.Errorf("error creating join request: %v", err)
+	}
+
+	return request, nil
+}
+
+// hostGameCard загружает карточку из параметра пути и проверяет, что запрос делает её хост
+func hostGameCard(c *gin.Context) (models.GameCard, bool) {
+	gameCardID, err := primitive.ObjectIDFromHex(c.Param("gameCardID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Id is incorrect"})
+		return models.GameCard{}, false
+	}
+
+	gameCard, err := getGameCardByID(c, gameCardID)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "GameCard not found"})
+		return gameCard, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving gameCard data"})
+		return gameCard, false
+	}
+
+	if err := helper.MatchUserTypeToUid(c, gameCard.HostUser.UserID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the host can manage join requests"})
+		return gameCard, false
+	}
+
+	return gameCard, true
+}
+
+// pendingJoinRequest находит ожидающую заявку карточки по параметру пути
+func pendingJoinRequest(ctx context.Context, gameCardID primitive.ObjectID, requestIDHex string) (models.JoinRequest, error) {
+	var request models.JoinRequest
+
+	requestID, err := primitive.ObjectIDFromHex(requestIDHex)
+	if err != nil {
+		return request, ErrJoinRequestNotFound
+	}
+
+	err = joinRequestCollection.FindOne(ctx, bson.M{"_id": requestID, "gamecard_id": gameCardID}).Decode(&request)
+	if err == mongo.ErrNoDocuments {
+		return request, ErrJoinRequestNotFound
+	}
+	if err != nil {
+		return request, fmt.Errorf("error retrieving join request: %v", err)
+	}
+	if request.Status != models.JoinRequestPending {
+		return request, ErrJoinRequestDecided
+	}
+
+	return request, nil
+}
+
+// decideJoinRequest переводит заявку из "pending" в итоговый статус условным обновлением
+func decideJoinRequest(ctx context.Context, requestID primitive.ObjectID, status string, decidedBy string) error {
+	result, err := joinRequestCollection.UpdateOne(ctx,
+		bson.M{"_id": requestID, "status": models.JoinRequestPending},
+		bson.M{"$set": bson.M{"status": status, "decided_by": decidedBy, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating join request: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrJoinRequestDecided
+	}
+	return nil
+}
+
+// reopenJoinRequest возвращает принятую заявку в "pending", если игрока не удалось добавить в карточку
+func reopenJoinRequest(ctx context.Context, requestID primitive.ObjectID) error {
+	_, err := joinRequestCollection.UpdateOne(ctx,
+		bson.M{"_id": requestID, "status": models.JoinRequestAccepted},
+		bson.M{
+			"$set":   bson.M{"status": models.JoinRequestPending, "updated_at": time.Now()},
+			"$unset": bson.M{"decided_by": ""},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("error reopening join request: %v", err)
+	}
+	return nil
+}
+
+func respondJoinRequestError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrJoinRequestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrJoinRequestDecided):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// joinRequestSorts — сортировки списка заявок (параметр sort_by), по умолчанию — старые первыми
+var joinRequestSorts = pagination.Sorts{
+	"created_at": {Field: "created_at"},
+}
+
+func GetJoinRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		gameCard, ok := hostGameCard(c)
+		if !ok {
+			return
+		}
+
+		status := c.DefaultQuery("status", models.JoinRequestPending)
+
+		params, err := pagination.ParseParams(c, joinRequestSorts, "created_at", false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := pagination.Find[models.JoinRequest](ctx, joinRequestCollection, bson.M{"gamecard_id": gameCard.ID, "status": status}, params)
+		respondPage(c, page, err)
+	}
+}
+
+func AcceptJoinRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hostID, _ := c.Get("uid")
+		hostIDString := fmt.Sprintf("%v", hostID)
+
+		gameCard, ok := hostGameCard(c)
+		if !ok {
+			return
+		}
+
+		request, err := pendingJoinRequest(c, gameCard.ID, c.Param("requestID"))
+		if err != nil {
+			respondJoinRequestError(c, err)
+			return
+		}
+
+		// Сначала заявка занимается условным обновлением: из двух одновременных
+		// решений по ней игрока добавит только одно
+		if err := decideJoinRequest(c, request.ID, models.JoinRequestAccepted, hostIDString); err != nil {
+			respondJoinRequestError(c, err)
+			return
+		}
+
+		// Принятый игрок проходит те же атомарные проверки, что и при обычном присоединении
+		updatedCard, err := AddPlayerToGameCard(c, request.Player.UserID, gameCard.ID)
+		if err != nil {
+			if reopenErr := reopenJoinRequest(c, request.ID); reopenErr != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": reopenErr.Error()})
+				return
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := onPlayerJoined(c, &updatedCard); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Join request accepted"})
+	}
+}
+
+func RejectJoinRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hostID, _ := c.Get("uid")
+		hostIDString := fmt.Sprintf("%v", hostID)
+
+		gameCard, ok := hostGameCard(c)
+		if !ok {
+			return
+		}
+
+		request, err := pendingJoinRequest(c, gameCard.ID, c.Param("requestID"))
+		if err != nil {
+			respondJoinRequestError(c, err)
+			return
+		}
+
+		if err := decideJoinRequest(c, request.ID, models.JoinRequestRejected, hostIDString); err != nil {
+			respondJoinRequestError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Join request rejected"})
+	}
+}
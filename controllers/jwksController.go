@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	helper "oiynlike/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS публикует открытые ключи, которыми другие сервисы могут проверять токены oiynlike
+func JWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, err := helper.PublicJWKS()
+		if err != nil {
+			log.Printf("Error loading signing keys: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error loading signing keys"})
+			return
+		}
+
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, gin.H{"keys": keys})
+	}
+}
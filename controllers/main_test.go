@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testMailLog — файл, в который LogMailer пишет письма тестов
+var testMailLog string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "oiynlike-mail")
+	if err != nil {
+		panic(err)
+	}
+	testMailLog = filepath.Join(dir, "mail.log")
+	os.Setenv("MAIL_DRIVER", "log")
+	os.Setenv("MAIL_LOG_FILE", testMailLog)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// requireMongo пропускает тест без тестовой MongoDB.
+// База задаётся до запуска тестов: MONGODB_URL=... MONGODB_DATABASE=oiynlike_test go test ./...
+func requireMongo(t *testing.T) {
+	t.Helper()
+	if os.Getenv("MONGODB_URL") == "" {
+		t.Skip("MONGODB_URL is not set")
+	}
+}
+
+// insertTestUser создаёт пользователя с паролем и включённой 2FA и удаляет его после теста
+func insertTestUser(t *testing.T, emailVerified bool) models.User {
+	t.Helper()
+
+	id := primitive.NewObjectID()
+	user := models.User{
+		ID:            id,
+		UserId:        id.Hex(),
+		FirstName:     "Test",
+		LastName:      "User",
+		Email:         id.Hex() + "@example.com",
+		Password:      "password-hash",
+		UserType:      helper.RoleUser,
+		EmailVerified: emailVerified,
+		MFA:           models.MFA{Enabled: true, Secret: "secret"},
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	ctx := context.Background()
+	if _, err := userCollection.InsertOne(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		userCollection.DeleteOne(context.Background(), bson.M{"user_id": user.UserId})
+	})
+	return user
+}
@@ -2,8 +2,10 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	helper "oiynlike/helpers"
 	"oiynlike/models"
 	"time"
 
@@ -42,48 +44,100 @@ func GetUserByID(ctx context.Context, userID string) (models.User, error) {
 	return user, nil
 }
 
-func AddPlayerToGameCard(c *gin.Context, userID string, gameCardID primitive.ObjectID) error {
+var (
+	ErrGameCardNotFound  = errors.New("gameCard not found")
+	ErrGameCardNotActive = errors.New("gameCard is not open for joining")
+	ErrHostCannotJoin    = errors.New("host user is alredy joined by default")
+	ErrAlreadyJoined     = errors.New("user already joined the gameCard")
+	ErrGameCardFull      = errors.New("gameCard is full")
+	ErrAlreadyWaitlisted = errors.New("user is already on the waitlist")
+	ErrRosterExceedsMax  = errors.New("max_players cannot be lower than the current number of players")
+)
+
+// AddPlayerToGameCard добавляет пользователя в matched_players одной условной
+// атомарной операцией: карточка должна быть активной, пользователь — не хостом
+// и не участником, а мест должно хватать. Возвращает карточку после добавления.
+func AddPlayerToGameCard(c *gin.Context, userID string, gameCardID primitive.ObjectID) (models.GameCard, error) {
+	var updatedCard models.GameCard
+
 	// Получаем данные пользователя из коллекции users по user_id
 	user, err := GetUserByID(c, userID)
 	if err != nil {
-		return fmt.Errorf("error retrieving user data: %v", err)
+		return updatedCard, fmt.Errorf("error retrieving user data: %v", err)
+	}
+
+	newMatchedPlayer := matchedPlayerFromUser(user)
+
+	filter := bson.M{
+		"_id":                     gameCardID,
+		"status":                  models.GameCardStatusActive,
+		"host_user.user_id":       bson.M{"$ne": userID},
+		"matched_players.user_id": bson.M{"$ne": userID},
+		"waitlist.user_id":        bson.M{"$ne": userID},
+		"$expr": bson.M{"$lt": bson.A{
+			bson.M{"$size": bson.M{"$ifNull": bson.A{"$matched_players", bson.A{}}}},
+			"$max_players",
+		}},
+	}
+	update := bson.M{
+		"$push": bson.M{"matched_players": newMatchedPlayer},
+		"$set":  bson.M{"updated_at": time.Now()},
 	}
 
-	// Получаем gameCard по gameCardID
-	gameCard, err := getGameCardByID(c, gameCardID)
+	err = gameCardCollection.FindOneAndUpdate(c, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedCard)
+	if err == mongo.ErrNoDocuments {
+		return updatedCard, joinRejectionReason(c, userID, gameCardID)
+	}
+	if err != nil {
+		return updatedCard, fmt.Errorf("error updating gameCard: %v", err)
+	}
+
+	return updatedCard, nil
+}
+
+// joinRejectionReason объясняет, какое из условий присоединения не выполнилось
+func joinRejectionReason(ctx context.Context, userID string, gameCardID primitive.ObjectID) error {
+	gameCard, err := getGameCardByID(ctx, gameCardID)
+	if err == mongo.ErrNoDocuments {
+		return ErrGameCardNotFound
+	}
 	if err != nil {
 		return fmt.Errorf("error retrieving gameCard data: %v", err)
 	}
 
 	if userID == gameCard.HostUser.UserID {
-		return fmt.Errorf("host user is alredy joined by default")
+		return ErrHostCannotJoin
 	}
-
-	// Проверяем, что пользователь еще не добавлен в matchedPlayers
 	for _, player := range gameCard.MatchedPlayers {
 		if player.UserID == userID {
-			return fmt.Errorf("user already joined the gameCard")
+			return ErrAlreadyJoined
+		}
+	}
+	for _, player := range gameCard.Waitlist {
+		if player.UserID == userID {
+			return ErrAlreadyWaitlisted
 		}
 	}
+	// Заполненная карточка переводится в "full", но в лист ожидания записаться можно
+	if len(gameCard.MatchedPlayers) >= gameCard.MaxPlayers && (gameCard.Status == models.GameCardStatusActive || gameCard.Status == models.GameCardStatusFull) {
+		return ErrGameCardFull
+	}
+	if gameCard.Status != models.GameCardStatusActive {
+		return ErrGameCardNotActive
+	}
+	return ErrGameCardFull
+}
 
-	// Добавляем пользователя в matchedPlayers
-	newMatchedPlayer := models.MatchedPlayer{
+func matchedPlayerFromUser(user models.User) models.MatchedPlayer {
+	return models.MatchedPlayer{
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
-		UserID:    userID,
+		UserID:    user.UserId,
 		PhotoURL:  user.PhotoURL,
 		City:      user.City,
 	}
-
-	gameCard.MatchedPlayers = append(gameCard.MatchedPlayers, newMatchedPlayer)
-
-	// Обновляем gameCard в базе данных
-	err = updateGameCard(c, gameCardID, gameCard)
-	if err != nil {
-		return fmt.Errorf("error updating gameCard: %v", err)
-	}
-
-	return nil
 }
 
 func JoinGameCard() gin.HandlerFunc {
@@ -99,28 +153,38 @@ func JoinGameCard() gin.HandlerFunc {
 			return
 		}
 
-		// Добавляем пользователя к игровой карте
-		err := AddPlayerToGameCard(c, userIDString, joinRequest.GameCardID)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		// Карточки с политикой "approval" принимают только заявки, которые рассматривает хост
+		if requiresApproval, err := gameCardRequiresApproval(c, joinRequest.GameCardID); err != nil {
+			respondJoinError(c, err)
+			return
+		} else if requiresApproval {
+			request, err := createJoinRequest(c, userIDString, joinRequest.GameCardID)
+			if err != nil {
+				respondJoinError(c, err)
+				return
+			}
+			c.JSON(http.StatusAccepted, gin.H{"msg": "Join request sent to the host", "request": request})
 			return
 		}
 
-		// Получаем данные игровой карты
-		gameCard, err := getGameCardByID(c, joinRequest.GameCardID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving gameCard data"})
+		// Добавляем пользователя к игровой карте и получаем её состояние сразу после добавления
+		gameCard, err := AddPlayerToGameCard(c, userIDString, joinRequest.GameCardID)
+		if errors.Is(err, ErrGameCardFull) {
+			// Мест нет — записываем в лист ожидания
+			position, err := AddPlayerToWaitlist(c, userIDString, joinRequest.GameCardID)
+			if err != nil {
+				respondJoinError(c, err)
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"msg": "GameCard is full, user added to the waitlist", "waitlist_position": position})
 			return
 		}
-
-		// Создаем чат, если необходимо
-		err = CreateChatIfNeeded(c, &gameCard)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondJoinError(c, err)
 			return
 		}
 
-		err = ChangeStatusIfNeeded(c, joinRequest.GameCardID, &gameCard)
+		err = onPlayerJoined(c, &gameCard)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -131,31 +195,150 @@ func JoinGameCard() gin.HandlerFunc {
 	}
 }
 
+// respondJoinError отвечает клиенту, почему присоединиться к карточке не удалось
+func respondJoinError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrGameCardNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrGameCardNotActive), errors.Is(err, ErrHostCannotJoin), errors.Is(err, ErrAlreadyJoined),
+		errors.Is(err, ErrAlreadyWaitlisted), errors.Is(err, ErrGameCardFull):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// checkQuorum фиксирует момент, когда состав впервые достиг MinPlayers: создаёт чат
+// и один раз уведомляет хоста и игроков. Событие срабатывает, каким бы путём ни
+// пополнился состав (присоединение, заявка, лист ожидания, снижение MinPlayers),
+// а условное обновление quorum_reached_at не даёт ему сработать дважды.
+// Если позже игроков станет меньше MinPlayers, кворум не сбрасывается.
+func checkQuorum(c *gin.Context, gameCard *models.GameCard) error {
+	if len(gameCard.MatchedPlayers) < gameCard.MinPlayers {
+		return nil
+	}
+
+	if err := CreateChatIfNeeded(c, gameCard); err != nil {
+		return err
+	}
+
+	if !gameCard.QuorumReachedAt.IsZero() {
+		return nil
+	}
+
+	now := time.Now()
+	filter := bson.M{
+		"_id":               gameCard.ID,
+		"quorum_reached_at": bson.M{"$exists": false},
+		"$expr": bson.M{"$gte": bson.A{
+			bson.M{"$size": bson.M{"$ifNull": bson.A{"$matched_players", bson.A{}}}},
+			"$min_players",
+		}},
+	}
+	result, err := gameCardCollection.UpdateOne(c, filter, bson.M{"$set": bson.M{"quorum_reached_at": now}})
+	if err != nil {
+		return fmt.Errorf("error updating gameCard quorum: %v", err)
+	}
+	if result.ModifiedCount == 0 {
+		return nil
+	}
+	gameCard.QuorumReachedAt = now
+
+	recipients := []string{gameCard.HostUser.UserID}
+	for _, player := range gameCard.MatchedPlayers {
+		recipients = append(recipients, player.UserID)
+	}
+
+	return helper.NotifyUsers(c, recipients, models.Notification{
+		Type:       models.NotificationGameCardQuorum,
+		Title:      "Игроки набраны",
+		Body:       fmt.Sprintf("В игре «%s» набралось минимальное число игроков, игра состоится", gameCard.Title),
+		GameCardID: gameCard.ID,
+	})
+}
+
+// syncRoster приводит карточку в соответствие с составом после его уменьшения или
+// изменения лимитов: продвигает лист ожидания на свободные места, проверяет кворум,
+// открывает или закрывает набор
+func syncRoster(c *gin.Context, gameCard *models.GameCard) error {
+	promoted, err := promoteFromWaitlist(c, gameCard)
+	if err != nil {
+		return err
+	}
+	for _, player := range promoted {
+		if err := addChatMember(c, gameCard.ID, player); err != nil {
+			return err
+		}
+	}
+
+	if err := checkQuorum(c, gameCard); err != nil {
+		return err
+	}
+
+	if err := reopenIfNeeded(c, gameCard); err != nil {
+		return err
+	}
+
+	return ChangeStatusIfNeeded(c, gameCard.ID, gameCard)
+}
+
+// onPlayerJoined создаёт чат, если необходимо, или добавляет нового игрока в уже
+// созданный, и закрывает набор, если карточка заполнилась
+func onPlayerJoined(c *gin.Context, gameCard *models.GameCard) error {
+	if err := checkQuorum(c, gameCard); err != nil {
+		return err
+	}
+
+	if err := addChatMember(c, gameCard.ID, gameCard.MatchedPlayers[len(gameCard.MatchedPlayers)-1]); err != nil {
+		return err
+	}
+
+	return ChangeStatusIfNeeded(c, gameCard.ID, gameCard)
+}
+
 func ChangeStatusIfNeeded(ctx context.Context, gameCardID primitive.ObjectID, gameCard *models.GameCard) error {
 	// Проверяем, равно ли количество присоединенных пользователей количеству нужных игроков
-	if len(gameCard.MatchedPlayers) == gameCard.MaxPlayers {
-		// Меняем статус карточки игры на "inactive"
-		gameCard.Status = "inactive"
+	if gameCard.Status == models.GameCardStatusActive && len(gameCard.MatchedPlayers) >= gameCard.MaxPlayers {
+		// Переводим карточку в "full" условным обновлением,
+		// чтобы не перезаписать игроков, присоединившихся параллельно
+		condition := bson.M{
+			"$expr": bson.M{"$gte": bson.A{
+				bson.M{"$size": bson.M{"$ifNull": bson.A{"$matched_players", bson.A{}}}},
+				"$max_players",
+			}},
+		}
 
-		// Обновляем карточку игры в базе данных
-		err := updateGameCard(ctx, gameCardID, *gameCard)
-		if err != nil {
-			return fmt.Errorf("error updating gameCard status: %v", err)
+		err := transitionGameCardStatus(ctx, gameCard, models.GameCardStatusFull, helper.StatusActorSystem, "", "", condition)
+		if err != nil && !errors.Is(err, ErrGameCardStatusChanged) {
+			return err
 		}
 	}
 	return nil
 }
 
+// maxUpdateAttempts — сколько раз updateGameCard повторяет обновление, если состав меняется параллельно
+const maxUpdateAttempts = 3
+
 // updateGameCard обновляет gameCard с заданным gameCardID
 func updateGameCard(ctx context.Context, gameCardID primitive.ObjectID, updatedGameCard models.GameCard) error {
 
 	// Формируем фильтр по _id
 	filter := bson.M{"_id": gameCardID}
 
+	// MaxPlayers нельзя опустить ниже текущего состава: условие проверяется в том же
+	// обновлении, чтобы параллельно присоединившийся игрок не оказался сверх лимита
+	if updatedGameCard.MaxPlayers != 0 {
+		filter["$expr"] = bson.M{"$lte": bson.A{
+			bson.M{"$size": bson.M{"$ifNull": bson.A{"$matched_players", bson.A{}}}},
+			updatedGameCard.MaxPlayers,
+		}}
+	}
+
 	// Формируем динамический BSON-документ с учетом только указанных полей
 	updateFields := bson.D{
 		{Key: "$set", Value: bson.D{}},
 	}
+	unsetFields := bson.D{}
 
 	if updatedGameCard.Title != "" {
 		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "title", Value: updatedGameCard.Title})
@@ -166,12 +349,21 @@ func updateGameCard(ctx context.Context, gameCardID primitive.ObjectID, updatedG
 	if updatedGameCard.Description != "" {
 		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "description", Value: updatedGameCard.Description})
 	}
-	if updatedGameCard.Status != "" {
-		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "status", Value: updatedGameCard.Status})
-	}
 	if updatedGameCard.City != "" {
 		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "city", Value: updatedGameCard.City})
 	}
+	if !updatedGameCard.AnticafeID.IsZero() {
+		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "anticafe_id", Value: updatedGameCard.AnticafeID})
+	}
+	if updatedGameCard.Venue != nil {
+		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "venue", Value: updatedGameCard.Venue})
+	}
+	if updatedGameCard.Location != nil {
+		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "location", Value: updatedGameCard.Location})
+	} else if !updatedGameCard.AnticafeID.IsZero() {
+		// Точка карточки уже выбрана attachVenue: у нового антикафе её может не быть
+		unsetFields = append(unsetFields, bson.E{Key: "location", Value: ""})
+	}
 	if updatedGameCard.CoverURL != "" {
 		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "cover_url", Value: updatedGameCard.CoverURL})
 	}
@@ -181,38 +373,63 @@ func updateGameCard(ctx context.Context, gameCardID primitive.ObjectID, updatedG
 	if updatedGameCard.MinPlayers != 0 {
 		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "min_players", Value: updatedGameCard.MinPlayers})
 	}
+	if updatedGameCard.JoinPolicy != "" {
+		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "join_policy", Value: updatedGameCard.JoinPolicy})
+	}
 	if !updatedGameCard.ScheduledTime.IsZero() {
 		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "scheduled_time", Value: updatedGameCard.ScheduledTime})
+		// Напоминание о перенесённой игре отправляется заново
+		unsetFields = append(unsetFields, bson.E{Key: "reminder_sent", Value: ""})
+	}
+	if !updatedGameCard.EndTime.IsZero() {
+		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "end_time", Value: updatedGameCard.EndTime})
+	}
+	if updatedGameCard.TimeZone != "" {
+		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "time_zone", Value: updatedGameCard.TimeZone})
 	}
 
-	// Обновляем matched_players
-	if len(updatedGameCard.MatchedPlayers) > 0 {
-		players := make([]interface{}, len(updatedGameCard.MatchedPlayers))
-		for i, player := range updatedGameCard.MatchedPlayers {
-			players[i] = bson.M{
-				"first_name": player.FirstName,
-				"last_name":  player.LastName,
-				"user_id":    player.UserID,
-				"photo_url":  player.PhotoURL,
-				"city":       player.City,
-			}
-		}
-		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "matched_players", Value: players})
+	if updatedGameCard.SeriesOverride {
+		updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "series_override", Value: true})
 	}
 
+	// matched_players здесь не перезаписывается: состав меняется только
+	// атомарными операциями (см. AddPlayerToGameCard). Статус тоже не меняется:
+	// переходы проходят через transitionGameCardStatus.
+
 	// Добавляем обновление поля "updated_at"
 	updateFields[0].Value = append(updateFields[0].Value.(bson.D), bson.E{Key: "updated_at", Value: time.Now()})
+	if len(unsetFields) > 0 {
+		updateFields = append(updateFields, bson.E{Key: "$unset", Value: unsetFields})
+	}
 
 	// Опции для FindOneAndUpdate
 	options := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
-	// Выполняем обновление в базе данных
-	result := gameCardCollection.FindOneAndUpdate(ctx, filter, updateFields, options)
-	if result.Err() != nil {
-		if result.Err() == mongo.ErrNoDocuments {
-			return fmt.Errorf("GameCard not found")
+	// Выполняем обновление в базе данных. Если условие по составу не выполнилось, а после
+	// перечитывания состав укладывается в лимит, игроки ушли параллельно — пробуем снова.
+	var result *mongo.SingleResult
+	for attempt := 0; ; attempt++ {
+		result = gameCardCollection.FindOneAndUpdate(ctx, filter, updateFields, options)
+		if result.Err() == nil {
+			break
+		}
+		if result.Err() != mongo.ErrNoDocuments {
+			return fmt.Errorf("error updating gameCard: %v", result.Err())
+		}
+
+		gameCard, err := getGameCardByID(ctx, gameCardID)
+		if err == mongo.ErrNoDocuments {
+			return ErrGameCardNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("error retrieving gameCard data: %v", err)
+		}
+		if len(gameCard.MatchedPlayers) > updatedGameCard.MaxPlayers {
+			return ErrRosterExceedsMax
+		}
+		if attempt == maxUpdateAttempts-1 {
+			return fmt.Errorf("gameCard was changed concurrently")
 		}
-		return fmt.Errorf("error updating gameCard: %v", result.Err())
 	}
 
 	// Декодируем обновленные данные
@@ -221,5 +438,9 @@ func updateGameCard(ctx context.Context, gameCardID primitive.ObjectID, updatedG
 		return fmt.Errorf("error decoding updated gameCard: %v", err)
 	}
 
+	if updatedGameCard.Title != "" || updatedGameCard.Description != "" {
+		indexGameCard(ctx, updatedCard)
+	}
+
 	return nil
 }
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"oiynlike/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// insertTestGameCard создаёт активную карточку без игроков и удаляет её после теста
+func insertTestGameCard(t *testing.T, maxPlayers int) models.GameCard {
+	t.Helper()
+
+	gameCard := models.GameCard{
+		ID:             primitive.NewObjectID(),
+		HostUser:       models.HostUser{UserID: primitive.NewObjectID().Hex()},
+		Title:          "Test game",
+		Description:    "Test game",
+		City:           "Almaty",
+		MinPlayers:     1,
+		MaxPlayers:     maxPlayers,
+		Status:         models.GameCardStatusActive,
+		JoinPolicy:     models.JoinPolicyOpen,
+		MatchedPlayers: []models.MatchedPlayer{},
+		Waitlist:       []models.MatchedPlayer{},
+		ScheduledTime:  time.Now().Add(24 * time.Hour),
+		EndTime:        time.Now().Add(26 * time.Hour),
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+
+	if _, err := gameCardCollection.InsertOne(context.Background(), gameCard); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		gameCardCollection.DeleteOne(context.Background(), bson.M{"_id": gameCard.ID})
+	})
+	return gameCard
+}
+
+func TestAddPlayerToGameCardConcurrently(t *testing.T) {
+	requireMongo(t)
+
+	tests := []struct {
+		name       string
+		players    int
+		maxPlayers int
+	}{
+		{"more players than seats", 10, 4},
+		{"fewer players than seats", 5, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameCard := insertTestGameCard(t, tt.maxPlayers)
+
+			users := make([]models.User, tt.players)
+			for i := range users {
+				users[i] = insertTestUser(t, true)
+			}
+
+			// Каждый игрок присоединяется дважды одновременно с остальными
+			var wg sync.WaitGroup
+			for _, user := range users {
+				for attempt := 0; attempt < 2; attempt++ {
+					wg.Add(1)
+					go func(userID string) {
+						defer wg.Done()
+						c, _ := gin.CreateTestContext(httptest.NewRecorder())
+						AddPlayerToGameCard(c, userID, gameCard.ID)
+					}(user.UserId)
+				}
+			}
+			wg.Wait()
+
+			stored, err := getGameCardByID(context.Background(), gameCard.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := tt.players
+			if tt.maxPlayers < expected {
+				expected = tt.maxPlayers
+			}
+			if len(stored.MatchedPlayers) != expected {
+				t.Fatalf("expected %d matched players, got %d", expected, len(stored.MatchedPlayers))
+			}
+
+			seen := make(map[string]bool)
+			for _, player := range stored.MatchedPlayers {
+				if seen[player.UserID] {
+					t.Fatalf("player %s joined twice", player.UserID)
+				}
+				seen[player.UserID] = true
+			}
+		})
+	}
+}
+
+func TestJoinGameCardUnknownCard(t *testing.T) {
+	requireMongo(t)
+
+	recorder := postJSON(t, JoinGameCard(), JoinRequest{GameCardID: primitive.NewObjectID()})
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
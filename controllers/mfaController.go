@@ -0,0 +1,341 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const recoveryCodesCount = 10
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// respondMFARequired отвечает на вход пользователя с 2FA: вместо токенов
+// выдаётся короткоживущий токен для POST api/users/login/mfa
+func respondMFARequired(c *gin.Context, user models.User) {
+	mfaToken, err := helper.GenerateMFAPendingToken(user.UserId)
+	if err != nil {
+		log.Printf("Error generating mfa token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't generate jwt"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": mfaToken})
+}
+
+// verifySecondFactor проверяет TOTP-код или одноразовый код восстановления.
+// Использованный код больше не принимается.
+func verifySecondFactor(ctx context.Context, user models.User, code string, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		hash := helper.HashRecoveryCode(recoveryCode)
+		result, err := userCollection.UpdateOne(ctx,
+			bson.M{"user_id": user.UserId, "mfa.recovery_codes": hash},
+			bson.M{"$pull": bson.M{"mfa.recovery_codes": hash}},
+		)
+		if err != nil {
+			return false, err
+		}
+		return result.ModifiedCount == 1, nil
+	}
+
+	step, ok := helper.ValidateTOTP(user.MFA.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	// Запоминаем интервал, чтобы тот же код нельзя было предъявить ещё раз
+	result, err := userCollection.UpdateOne(ctx,
+		bson.M{"user_id": user.UserId, "mfa.last_used_step": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"mfa.last_used_step": step}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// @Summary Second factor login
+// @Description Completes login for users with two-factor authentication using a TOTP code or a recovery code
+// @ID user-login-mfa
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param request body MFALoginRequest true "MFA token and code"
+// @Success 200 {object} models.User "Successful login"
+// @Failure 400 {object} ErrorResponse "Bad Request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Router /users/login/mfa [post]
+func LoginMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request MFALoginRequest
+		if err := c.ShouldBindJSON(&request); err != nil || (request.Code == "" && request.RecoveryCode == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token and code or recovery_code are required"})
+			return
+		}
+
+		userID, err := helper.ValidateMFAPendingToken(request.MFAToken)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		var user models.User
+		err = userCollection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user)
+		if err != nil || !user.MFA.Enabled {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		// Коды подбираются так же, как пароли
+		if err := loginGuard.Check(ctx, user.Email, c.ClientIP()); err != nil {
+			abortLoginBlocked(c, err)
+			return
+		}
+
+		ok, err := verifySecondFactor(ctx, user, request.Code, request.RecoveryCode)
+		if err != nil {
+			log.Printf("Error verifying second factor: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error verifying code"})
+			return
+		}
+		if !ok {
+			recordLoginFailure(ctx, user.Email, c.ClientIP())
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "code is incorrect"})
+			return
+		}
+
+		if err := loginGuard.RecordSuccess(ctx, user.Email); err != nil {
+			log.Printf("Error resetting login attempts: %v", err)
+		}
+
+		token, refreshToken, err := startSession(c, ctx, user, true)
+		if err != nil {
+			log.Printf("Error starting session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't generate jwt"})
+			return
+		}
+
+		err = userCollection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		user.Token = token
+		user.RefreshToken = refreshToken
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// EnrollTOTP создаёт новый секрет и возвращает URI для QR-кода.
+// 2FA включается только после подтверждения кодом (ConfirmTOTP).
+func EnrollTOTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if user.MFA.Enabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+
+		secret, err := helper.GenerateTOTPSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating secret"})
+			return
+		}
+
+		_, err = userCollection.UpdateOne(ctx,
+			bson.M{"user_id": user.UserId},
+			bson.M{"$set": bson.M{"mfa.pending_secret": secret, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving secret"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"secret":           secret,
+			"provisioning_uri": helper.TOTPProvisioningURI(secret, user.Email),
+		})
+	}
+}
+
+// ConfirmTOTP включает 2FA после проверки первого кода и выдаёт коды восстановления
+func ConfirmTOTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request MFACodeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+			return
+		}
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if user.MFA.PendingSecret == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Start enrollment first"})
+			return
+		}
+
+		step, ok := helper.ValidateTOTP(user.MFA.PendingSecret, request.Code, time.Now())
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code is incorrect"})
+			return
+		}
+
+		codes, hashes, err := helper.GenerateRecoveryCodes(recoveryCodesCount)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating recovery codes"})
+			return
+		}
+
+		mfa := models.MFA{
+			Enabled:       true,
+			Secret:        user.MFA.PendingSecret,
+			RecoveryCodes: hashes,
+			LastUsedStep:  step,
+			EnabledAt:     time.Now(),
+		}
+
+		_, err = userCollection.UpdateOne(ctx,
+			bson.M{"user_id": user.UserId, "mfa.pending_secret": user.MFA.PendingSecret},
+			bson.M{"$set": bson.M{"mfa": mfa, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error enabling two-factor authentication"})
+			return
+		}
+
+		// Коды показываются один раз, в базе хранятся только их хэши
+		c.JSON(http.StatusOK, gin.H{"msg": "Two-factor authentication enabled", "recovery_codes": codes})
+	}
+}
+
+// RegenerateRecoveryCodes заменяет коды восстановления новыми
+func RegenerateRecoveryCodes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		user, ok := bindSecondFactor(c, ctx)
+		if !ok {
+			return
+		}
+
+		codes, hashes, err := helper.GenerateRecoveryCodes(recoveryCodesCount)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating recovery codes"})
+			return
+		}
+
+		_, err = userCollection.UpdateOne(ctx,
+			bson.M{"user_id": user.UserId},
+			bson.M{"$set": bson.M{"mfa.recovery_codes": hashes, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving recovery codes"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// DisableTOTP отключает 2FA; для ролей, где она обязательна, это запрещено
+func DisableTOTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		user, ok := bindSecondFactor(c, ctx)
+		if !ok {
+			return
+		}
+
+		if helper.RoleRequiresMFA(user.UserType) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+			return
+		}
+
+		_, err := userCollection.UpdateOne(ctx,
+			bson.M{"user_id": user.UserId},
+			bson.M{"$set": bson.M{"mfa": models.MFA{}, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error disabling two-factor authentication"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Two-factor authentication disabled"})
+	}
+}
+
+// bindSecondFactor требует от пользователя с включённой 2FA действующий код
+// (поле code или recovery_code) перед изменением её настроек
+func bindSecondFactor(c *gin.Context, ctx context.Context) (models.User, bool) {
+	var request struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || (request.Code == "" && request.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return models.User{}, false
+	}
+
+	var user models.User
+	err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return user, false
+	}
+
+	if !user.MFA.Enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return user, false
+	}
+
+	ok, err := verifySecondFactor(ctx, user, request.Code, request.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error verifying code"})
+		return user, false
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "code is incorrect"})
+		return user, false
+	}
+
+	return user, true
+}
@@ -2,20 +2,22 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	helper "oiynlike/helpers"
 	"oiynlike/models"
+	"oiynlike/pagination"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func GetGameCardByID() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
+		id := c.Param("gameCardID")
 
 		objectID, err := primitive.ObjectIDFromHex(id)
 		if err != nil {
@@ -23,25 +25,19 @@ func GetGameCardByID() gin.HandlerFunc {
 			return
 		}
 
-		// Формируем фильтр для поиска пользователя по ID
-		filter := bson.M{"_id": objectID}
-
 		// Выполняем запрос к базе данных
-		var gameCard models.GameCard
-		err = gameCardCollection.FindOne(context.Background(), filter).Decode(&gameCard)
+		gameCard, err := getGameCardByID(context.Background(), objectID)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				c.JSON(http.StatusNotFound, gin.H{"error": "GameCard not found"})
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving gameCard data"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"user": gameCard})
-
+		c.JSON(http.StatusOK, gin.H{"data": gameCard})
 	}
-
 }
 
 func GetAllGameCards() gin.HandlerFunc {
@@ -49,44 +45,20 @@ func GetAllGameCards() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		// Запрашиваем активные игровые карты с учетом пагинации
-		cursor, err := gameCardCollection.Find(ctx, options.Find())
+		params, err := pagination.ParseParams(c, gameCardSorts, "created_at", true)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		defer cursor.Close(ctx)
-
-		// Проходим по результатам запроса и добавляем их в слайс
-		var gameCards []models.GameCard
-		if err := cursor.All(ctx, &gameCards); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		response := struct {
-			Items []models.GameCard `json:"items"`
-			Meta  struct {
-				Current  int `json:"current"`
-				Total    int `json:"total"`
-				PageSize int `json:"page_size"`
-			} `json:"meta"`
-		}{
-			Items: gameCards,
-			Meta: struct {
-				Current  int `json:"current"`
-				Total    int `json:"total"`
-				PageSize int `json:"page_size"`
-			}{
-				Current:  1,              // текущая страница (ваше значение)
-				Total:    len(gameCards), // общее количество элементов
-				PageSize: 10,             // размер страницы (ваше значение)
-			},
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
 		}
 
-		// Отправляем успешный ответ с активными gamecards
-		c.JSON(http.StatusOK, response)
-
+		// Запрашиваем игровые карты с учетом пагинации
+		page, err := pagination.Find[models.GameCard](ctx, gameCardCollection, filter, params)
+		respondPage(c, page, err)
 	}
 }
 
@@ -100,6 +72,8 @@ func UpdateStatus() gin.HandlerFunc {
 			return
 		}
 
+		moderatorID, _ := c.Get("uid")
+
 		// Извлечение значения параметра status из form-data
 		status := c.PostForm("status")
 
@@ -109,18 +83,35 @@ func UpdateStatus() gin.HandlerFunc {
 			return
 		}
 
-		// Создание объекта GameCard для обновления
-		updateData := models.GameCard{
-			Status: status,
+		if !helper.IsValidGameCardStatus(status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown status"})
+			return
 		}
 
-		// Вызовите функцию обновления gameCard
-		err = updateGameCard(context.Background(), objectID, updateData)
+		gameCard, err := getGameCardByID(context.Background(), objectID)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "GameCard not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving gameCard data"})
+			return
+		}
+
+		// Статус меняется только допустимым для модератора переходом
+		err = transitionGameCardStatus(context.Background(), &gameCard, status, helper.StatusActorModerator, fmt.Sprintf("%v", moderatorID), c.PostForm("reason"), nil)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating gameCard"})
+			respondStatusTransitionError(c, err)
 			return
 		}
 
+		if gameCard.Status == models.GameCardStatusCancelled {
+			if err := onGameCardCancelled(context.Background(), gameCard, c.PostForm("reason")); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
 		c.JSON(http.StatusOK, gin.H{"msg": "GameCard status updated successfully"})
 	}
 }
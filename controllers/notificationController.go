@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	helper "oiynlike/helpers"
+	"oiynlike/pagination"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// notificationSorts — сортировки списка уведомлений (параметр sort_by)
+var notificationSorts = pagination.Sorts{
+	"created_at": {Field: "created_at"},
+}
+
+func GetNotifications() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, _ := c.Get("uid")
+		userIDString := fmt.Sprintf("%v", userID)
+
+		params, err := pagination.ParseParams(c, notificationSorts, "created_at", true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := helper.GetUserNotifications(ctx, userIDString, c.Query("unread") == "true", params)
+		respondPage(c, page, err)
+	}
+}
+
+func MarkNotificationRead() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, _ := c.Get("uid")
+		userIDString := fmt.Sprintf("%v", userID)
+
+		notificationID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Id is incorrect"})
+			return
+		}
+
+		found, err := helper.MarkNotificationRead(ctx, userIDString, notificationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating notification"})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Notification marked as read"})
+	}
+}
@@ -0,0 +1,383 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type OIDCLoginRequest struct {
+	IDToken string `json:"id_token" binding:"required"`
+	Nonce   string `json:"nonce"`
+	// Apple присылает имя только в первом ответе и не кладёт его в ID-токен
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// verifyOIDCRequest проверяет ID-токен провайдера из параметра :provider
+func verifyOIDCRequest(c *gin.Context, request *OIDCLoginRequest) (string, helper.OIDCClaims, bool) {
+	providerName := c.Param("provider")
+
+	provider, ok := helper.GetOIDCProvider(providerName)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown login provider"})
+		return "", helper.OIDCClaims{}, false
+	}
+
+	if err := c.ShouldBindJSON(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id_token is required"})
+		return "", helper.OIDCClaims{}, false
+	}
+
+	claims, err := provider.VerifyIDToken(request.IDToken, request.Nonce)
+	if err != nil {
+		if !errors.Is(err, helper.ErrIDTokenInvalid) {
+			log.Printf("Error verifying id token: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": helper.ErrIDTokenInvalid.Error()})
+		return "", helper.OIDCClaims{}, false
+	}
+
+	return providerName, claims, true
+}
+
+// ErrEmailTaken — email из ID-токена уже занят аккаунтом, а провайдер его не подтвердил
+var ErrEmailTaken = errors.New("an account with this email already exists")
+
+func identityFilter(provider string, subject string) bson.M {
+	return bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}}
+}
+
+// EnsureUserIndexes создаёт уникальные индексы по email и по внешнему входу, чтобы
+// параллельные первые входы не заводили дубликаты аккаунтов
+func EnsureUserIndexes(ctx context.Context) error {
+	_, err := userCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"identities.subject": bson.M{"$exists": true}}),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error creating user indexes: %v", err)
+	}
+	return nil
+}
+
+// findOrCreateOIDCUser находит пользователя по внешнему входу, привязывает вход
+// к аккаунту с тем же email (подтверждённым провайдером) или создаёт нового пользователя
+func findOrCreateOIDCUser(ctx context.Context, provider string, claims helper.OIDCClaims, request OIDCLoginRequest) (models.User, error) {
+	var user models.User
+
+	err := userCollection.FindOne(ctx, identityFilter(provider, claims.Subject)).Decode(&user)
+	if err == nil {
+		return user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return user, err
+	}
+
+	identity := models.Identity{
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+		LinkedAt: time.Now(),
+	}
+
+	// Привязываем только по email, который провайдер подтвердил
+	if claims.Email != "" && claims.EmailVerified {
+		user, err = linkIdentityByEmail(ctx, claims.Email, identity)
+		if err != mongo.ErrNoDocuments {
+			return user, err
+		}
+	}
+
+	if claims.Email == "" {
+		return user, errors.New("provider didn't share an email")
+	}
+
+	firstName := claims.FirstName
+	if firstName == "" {
+		firstName = request.FirstName
+	}
+	lastName := claims.LastName
+	if lastName == "" {
+		lastName = request.LastName
+	}
+
+	user = models.User{
+		ID:            primitive.NewObjectID(),
+		FirstName:     firstName,
+		LastName:      lastName,
+		Email:         claims.Email,
+		UserType:      helper.RoleUser,
+		PhotoURL:      claims.PhotoURL,
+		EmailVerified: claims.EmailVerified,
+		Identities:    []models.Identity{identity},
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	user.UserId = user.ID.Hex()
+
+	_, err = userCollection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		// Аккаунт с этим входом или email успел создать параллельный запрос: входим в него
+		var existing models.User
+		err = userCollection.FindOne(ctx, identityFilter(provider, claims.Subject)).Decode(&existing)
+		if err != mongo.ErrNoDocuments {
+			return existing, err
+		}
+		if !claims.EmailVerified {
+			return user, ErrEmailTaken
+		}
+		return linkIdentityByEmail(ctx, claims.Email, identity)
+	}
+	return user, err
+}
+
+// linkIdentityByEmail привязывает внешний вход к аккаунту с email, который провайдер подтвердил.
+// Если такого аккаунта нет, возвращает mongo.ErrNoDocuments.
+func linkIdentityByEmail(ctx context.Context, email string, identity models.Identity) (models.User, error) {
+	var user models.User
+	err := userCollection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err != nil {
+		return user, err
+	}
+
+	set := bson.M{"email_verified": true, "updated_at": time.Now()}
+
+	// Неподтверждённый аккаунт мог заранее зарегистрировать кто угодно, указав чужой email.
+	// Владелец почты подтвердил её у провайдера, поэтому пароль, 2FA и сессии прежнего
+	// регистранта сбрасываются: пароль задаётся заново через сброс пароля.
+	takeover := !user.EmailVerified
+	if takeover {
+		set["password"] = ""
+		set["mfa"] = models.MFA{}
+	}
+
+	// Параллельный вход мог уже привязать этот же вход
+	_, err = userCollection.UpdateOne(ctx,
+		bson.M{"user_id": user.UserId, "identities": bson.M{"$not": bson.M{"$elemMatch": bson.M{"provider": identity.Provider, "subject": identity.Subject}}}},
+		bson.M{
+			"$push": bson.M{"identities": identity},
+			"$set":  set,
+		},
+	)
+	if err != nil {
+		return user, err
+	}
+
+	if takeover {
+		if err := helper.RevokeAllTokens(ctx, user.UserId); err != nil {
+			return user, err
+		}
+		user.Password = ""
+		user.MFA = models.MFA{}
+	}
+	user.EmailVerified = true
+	return user, nil
+}
+
+// @Summary Social login
+// @Description Logs in with an OpenID Connect ID token from Google or Apple, creating or linking the account, and returns the user details and tokens
+// @ID user-oidc-login
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param provider path string true "google or apple"
+// @Param request body OIDCLoginRequest true "ID token"
+// @Success 200 {object} models.User "Successful login"
+// @Failure 400 {object} ErrorResponse "Bad Request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 409 {object} ErrorResponse "Email already registered"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /users/oidc/{provider} [post]
+func OIDCLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request OIDCLoginRequest
+		provider, claims, ok := verifyOIDCRequest(c, &request)
+		if !ok {
+			return
+		}
+
+		user, err := findOrCreateOIDCUser(ctx, provider, claims, request)
+		if errors.Is(err, ErrEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Printf("Error signing in with %s: %v", provider, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't sign in"})
+			return
+		}
+
+		if user.MFA.Enabled {
+			respondMFARequired(c, user)
+			return
+		}
+
+		token, refreshToken, err := startSession(c, ctx, user, false)
+		if err != nil {
+			log.Printf("Error starting session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't generate jwt"})
+			return
+		}
+
+		err = userCollection.FindOne(ctx, bson.M{"user_id": user.UserId}).Decode(&user)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		user.Token = token
+		user.RefreshToken = refreshToken
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// @Summary Link social login
+// @Description Links a Google or Apple identity, proven by its OpenID Connect ID token, to the current user
+// @ID user-link-identity
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param provider path string true "google or apple"
+// @Param request body OIDCLoginRequest true "ID token"
+// @Success 200 {object} map[string]interface{} "Identity linked"
+// @Failure 400 {object} ErrorResponse "Bad Request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Unknown login provider"
+// @Failure 409 {object} ErrorResponse "Identity is linked to another account"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /user/identities/{provider} [post]
+func LinkIdentity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID := c.GetString("uid")
+
+		var request OIDCLoginRequest
+		provider, claims, ok := verifyOIDCRequest(c, &request)
+		if !ok {
+			return
+		}
+
+		var owner models.User
+		err := userCollection.FindOne(ctx, identityFilter(provider, claims.Subject)).Decode(&owner)
+		if err == nil {
+			if owner.UserId == userID {
+				c.JSON(http.StatusOK, gin.H{"msg": "Identity is already linked"})
+				return
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "Identity is linked to another account"})
+			return
+		}
+		if err != mongo.ErrNoDocuments {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error linking identity"})
+			return
+		}
+
+		identity := models.Identity{
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+			LinkedAt: time.Now(),
+		}
+
+		_, err = userCollection.UpdateOne(ctx,
+			bson.M{"user_id": userID},
+			bson.M{"$push": bson.M{"identities": identity}, "$set": bson.M{"updated_at": time.Now()}},
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			// Тот же вход параллельно привязали к другому аккаунту
+			c.JSON(http.StatusConflict, gin.H{"error": "Identity is linked to another account"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error linking identity"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Identity linked successfully", "data": identity})
+	}
+}
+
+// @Summary Unlink social login
+// @Description Removes the Google or Apple identity from the current user. The last sign-in method can't be removed while the account has no password
+// @ID user-unlink-identity
+// @Tags Users
+// @Produce json
+// @Param provider path string true "google or apple"
+// @Success 200 {object} map[string]string "Identity unlinked"
+// @Failure 400 {object} ErrorResponse "Bad Request"
+// @Failure 404 {object} ErrorResponse "Identity is not linked"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /user/identities/{provider} [delete]
+func UnlinkIdentity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID := c.GetString("uid")
+		provider := c.Param("provider")
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		remaining := 0
+		linked := false
+		for _, identity := range user.Identities {
+			if identity.Provider == provider {
+				linked = true
+			} else {
+				remaining++
+			}
+		}
+
+		if !linked {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Identity is not linked"})
+			return
+		}
+
+		// Нельзя оставить аккаунт без единого способа входа
+		if user.Password == "" && remaining == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Set a password before unlinking the last sign-in method"})
+			return
+		}
+
+		_, err = userCollection.UpdateOne(ctx,
+			bson.M{"user_id": userID},
+			bson.M{"$pull": bson.M{"identities": bson.M{"provider": provider}}, "$set": bson.M{"updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error unlinking identity"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Identity unlinked successfully"})
+	}
+}
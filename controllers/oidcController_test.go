@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"oiynlike/models"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	testOIDCIssuer   = "https://issuer.test"
+	testOIDCClientID = "test-client"
+	testOIDCKeyID    = "test-key"
+)
+
+var (
+	testOIDCKeyOnce sync.Once
+	testOIDCKey     *rsa.PrivateKey
+)
+
+// useTestOIDCIssuer поднимает локальный издатель и настраивает на него провайдера google.
+// Провайдеры читаются из окружения один раз, поэтому издатель общий для всех тестов пакета.
+func useTestOIDCIssuer(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	testOIDCKeyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			panic(err)
+		}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"keys": []map[string]string{{
+					"kid": testOIDCKeyID,
+					"kty": "RSA",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				}},
+			})
+		}))
+
+		os.Setenv("OIDC_GOOGLE_CLIENT_IDS", testOIDCClientID)
+		os.Setenv("OIDC_GOOGLE_ISSUERS", testOIDCIssuer)
+		os.Setenv("OIDC_GOOGLE_JWKS_URL", server.URL)
+		testOIDCKey = key
+	})
+	return testOIDCKey
+}
+
+func signTestIDToken(t *testing.T, key *rsa.PrivateKey, subject string, email string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            testOIDCIssuer,
+		"aud":            testOIDCClientID,
+		"sub":            subject,
+		"email":          email,
+		"email_verified": true,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+	})
+	token.Header["kid"] = testOIDCKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func oidcLogin(t *testing.T, idToken string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("api/users/oidc/:provider", OIDCLogin())
+
+	body, _ := json.Marshal(OIDCLoginRequest{IDToken: idToken})
+	request := httptest.NewRequest(http.MethodPost, "/api/users/oidc/google", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestOIDCLoginRejectsInvalidToken(t *testing.T) {
+	useTestOIDCIssuer(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := oidcLogin(t, signTestIDToken(t, otherKey, "forged", "forged@example.com"))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d: %s", recorder.Code, recorder.Body)
+	}
+}
+
+func TestOIDCLoginLinksExistingAccount(t *testing.T) {
+	requireMongo(t)
+	key := useTestOIDCIssuer(t)
+
+	t.Run("verified account keeps credentials", func(t *testing.T) {
+		user := insertTestUser(t, true)
+
+		recorder := oidcLogin(t, signTestIDToken(t, key, "verified-"+user.UserId, user.Email))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body)
+		}
+		// 2FA аккаунта по-прежнему требуется при входе через провайдера
+		var response map[string]interface{}
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		if response["mfa_required"] != true {
+			t.Fatalf("expected second factor to be required: %s", recorder.Body)
+		}
+
+		var stored models.User
+		if err := userCollection.FindOne(context.Background(), bson.M{"user_id": user.UserId}).Decode(&stored); err != nil {
+			t.Fatal(err)
+		}
+		if stored.Password != user.Password || !stored.MFA.Enabled {
+			t.Fatal("verified account lost its password or 2FA")
+		}
+		if len(stored.Identities) != 1 || stored.Identities[0].Provider != "google" {
+			t.Fatalf("identity is not linked: %+v", stored.Identities)
+		}
+	})
+
+	t.Run("unverified account is reset", func(t *testing.T) {
+		user := insertTestUser(t, false)
+
+		recorder := oidcLogin(t, signTestIDToken(t, key, "unverified-"+user.UserId, user.Email))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body)
+		}
+
+		var stored models.User
+		if err := userCollection.FindOne(context.Background(), bson.M{"user_id": user.UserId}).Decode(&stored); err != nil {
+			t.Fatal(err)
+		}
+		if stored.Password != "" || stored.MFA.Enabled {
+			t.Fatal("credentials of the unverified account were not reset")
+		}
+		if !stored.EmailVerified || len(stored.Identities) != 1 {
+			t.Fatalf("identity is not linked: %+v", stored)
+		}
+	})
+}
+
+func TestOIDCLoginConcurrentFirstLogins(t *testing.T) {
+	requireMongo(t)
+	key := useTestOIDCIssuer(t)
+
+	ctx := context.Background()
+	if err := EnsureUserIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	subject := primitive.NewObjectID().Hex()
+	email := subject + "@example.com"
+	t.Cleanup(func() {
+		userCollection.DeleteMany(context.Background(), bson.M{"email": email})
+	})
+
+	idToken := signTestIDToken(t, key, subject, email)
+
+	var wg sync.WaitGroup
+	codes := make([]int, 5)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = oidcLogin(t, idToken).Code
+		}(i)
+	}
+	wg.Wait()
+
+	for _, code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("expected every login to succeed, got %v", codes)
+		}
+	}
+
+	count, err := userCollection.CountDocuments(ctx, bson.M{"email": email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("expected one account, got %d", count)
+	}
+}
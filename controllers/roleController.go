@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	helper "oiynlike/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// admin
+func UpdateUserRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID := c.Param("user_id")
+
+		var request RoleRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role is required"})
+			return
+		}
+
+		if !helper.IsValidRole(request.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role"})
+			return
+		}
+
+		// Администратор не может снять роль с самого себя
+		if userID == c.GetString("uid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You can't change your own role"})
+			return
+		}
+
+		change, err := helper.ChangeUserRole(ctx, userID, request.Role, c.GetString("uid"), helper.RoleChangeSourceAPI)
+		if err != nil {
+			if errors.Is(err, helper.ErrUserNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			log.Printf("Error changing user role: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error changing user role"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "User role updated successfully", "data": change})
+	}
+}
@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// gameReminderLead — за сколько до начала игры игрокам приходит напоминание
+const gameReminderLead = 2 * time.Hour
+
+// defaultModerationTTL — сколько карточка может ждать модерации, прежде чем истечёт
+const defaultModerationTTL = 7 * 24 * time.Hour
+
+// defaultUploadRetention — сколько хранятся загруженные файлы, на которые никто не ссылается
+const defaultUploadRetention = 30 * 24 * time.Hour
+
+// uploadsDir — каталог, куда UploadPhoto сохраняет файлы
+const uploadsDir = "./uploads"
+
+// envDays читает длительность в днях из переменной окружения key
+func envDays(key string, fallback time.Duration) time.Duration {
+	days, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || days <= 0 {
+		return fallback
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// SendGameReminders напоминает игрокам об играх, которые начнутся в ближайшие два часа.
+// Карточка отмечается reminder_sent до отправки, поэтому напоминание приходит один раз.
+func SendGameReminders(ctx context.Context) error {
+	now := time.Now()
+	filter := bson.M{
+		"status":         bson.M{"$in": bson.A{models.GameCardStatusActive, models.GameCardStatusFull}},
+		"scheduled_time": bson.M{"$gt": now, "$lte": now.Add(gameReminderLead)},
+		"reminder_sent":  bson.M{"$ne": true},
+	}
+
+	cursor, err := gameCardCollection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var gameCards []models.GameCard
+	if err := cursor.All(ctx, &gameCards); err != nil {
+		return err
+	}
+
+	for _, gameCard := range gameCards {
+		result, err := gameCardCollection.UpdateOne(ctx,
+			bson.M{"_id": gameCard.ID, "reminder_sent": bson.M{"$ne": true}},
+			bson.M{"$set": bson.M{"reminder_sent": true}},
+		)
+		if err != nil {
+			return fmt.Errorf("error marking reminder as sent: %v", err)
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+
+		recipients := make([]string, 0, len(gameCard.MatchedPlayers))
+		for _, player := range gameCard.MatchedPlayers {
+			recipients = append(recipients, player.UserID)
+		}
+
+		err = helper.NotifyUsers(ctx, recipients, models.Notification{
+			Type:       models.NotificationGameCardReminder,
+			Title:      "Скоро игра",
+			Body:       fmt.Sprintf("Игра «%s» начнётся через 2 часа", gameCard.Title),
+			GameCardID: gameCard.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpireStaleModerationCards отменяет карточки, которые слишком долго ждут модерации
+// (MODERATION_TTL_DAYS) или время начала которых уже наступило
+func ExpireStaleModerationCards(ctx context.Context) error {
+	now := time.Now()
+	filter := bson.M{
+		"status": models.GameCardStatusModeration,
+		"$or": bson.A{
+			bson.M{"created_at": bson.M{"$lte": now.Add(-envDays("MODERATION_TTL_DAYS", defaultModerationTTL))}},
+			bson.M{"scheduled_time": bson.M{"$lte": now}},
+		},
+	}
+
+	_, err := transitionDueGameCards(ctx, filter, models.GameCardStatusCancelled, "moderation expired")
+	return err
+}
+
+// CloseFinishedChats закрывает чаты завершённых и отменённых игр
+func CloseFinishedChats(ctx context.Context) error {
+	filter := bson.M{
+		"status":      bson.M{"$in": bson.A{models.GameCardStatusCompleted, models.GameCardStatusCancelled}},
+		"chat_closed": bson.M{"$ne": true},
+	}
+
+	cursor, err := gameCardCollection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var gameCards []models.GameCard
+	if err := cursor.All(ctx, &gameCards); err != nil {
+		return err
+	}
+
+	for _, gameCard := range gameCards {
+		if gameCard.Status == models.GameCardStatusCompleted {
+			if err := postSystemMessage(ctx, gameCard.ID, "Игра завершена, чат закрыт"); err != nil {
+				return err
+			}
+		}
+
+		_, err := chatsCollection.UpdateMany(ctx,
+			bson.M{"gamecard_id": gameCard.ID},
+			bson.M{"$set": bson.M{"closed": true}},
+		)
+		if err != nil {
+			return fmt.Errorf("error closing chat: %v", err)
+		}
+
+		_, err = gameCardCollection.UpdateOne(ctx,
+			bson.M{"_id": gameCard.ID},
+			bson.M{"$set": bson.M{"chat_closed": true}},
+		)
+		if err != nil {
+			return fmt.Errorf("error updating gameCard: %v", err)
+		}
+	}
+	return nil
+}
+
+// uploadIsReferenced проверяет, ссылается ли на загруженный файл профиль, карточка, серия или антикафе
+func uploadIsReferenced(ctx context.Context, name string) (bool, error) {
+	pattern := bson.M{"$regex": "/uploads/" + regexp.QuoteMeta(name) + "$"}
+
+	checks := []func() (int64, error){
+		func() (int64, error) { return userCollection.CountDocuments(ctx, bson.M{"photo_url": pattern}) },
+		func() (int64, error) { return gameCardCollection.CountDocuments(ctx, bson.M{"cover_url": pattern}) },
+		func() (int64, error) { return seriesCollection.CountDocuments(ctx, bson.M{"cover_url": pattern}) },
+		func() (int64, error) { return anticafeCollection.CountDocuments(ctx, bson.M{"photos": pattern}) },
+	}
+
+	for _, check := range checks {
+		count, err := check()
+		if err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PurgeOldUploads удаляет загруженные файлы старше UPLOAD_RETENTION_DAYS, на которые никто не ссылается
+func PurgeOldUploads(ctx context.Context) error {
+	entries, err := os.ReadDir(uploadsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-envDays("UPLOAD_RETENTION_DAYS", defaultUploadRetention))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		referenced, err := uploadIsReferenced(ctx, entry.Name())
+		if err != nil {
+			return err
+		}
+		if referenced {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(uploadsDir, entry.Name())); err != nil {
+			log.Printf("Error removing upload %s: %v", entry.Name(), err)
+		}
+	}
+	return nil
+}
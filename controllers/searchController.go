@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"context"
+	"log"
+
+	"oiynlike/models"
+	"oiynlike/search"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// indexGameCard обновляет карточку в поисковом индексе. Ошибка индексации не отменяет
+// изменение карточки: она только логируется, а индекс можно перестроить командой reindex-search.
+func indexGameCard(ctx context.Context, gameCard models.GameCard) {
+	err := search.Default().Index(ctx, search.IndexGameCards, search.Document{
+		ID: gameCard.ID,
+		Fields: map[string]string{
+			"title":       gameCard.Title,
+			"description": gameCard.Description,
+		},
+	})
+	if err != nil {
+		log.Printf("Error indexing gameCard %s: %v", gameCard.ID.Hex(), err)
+	}
+}
+
+// indexAnticafe обновляет антикафе в поисковом индексе
+func indexAnticafe(ctx context.Context, anticafe models.AnticafeModel) {
+	err := search.Default().Index(ctx, search.IndexAnticafes, search.Document{
+		ID: anticafe.ID,
+		Fields: map[string]string{
+			"title":       anticafe.Title,
+			"address":     anticafe.Address,
+			"description": anticafe.Description,
+		},
+	})
+	if err != nil {
+		log.Printf("Error indexing anticafe %s: %v", anticafe.ID.Hex(), err)
+	}
+}
+
+// reindexGameCards переиндексирует все карточки, подходящие под filter
+func reindexGameCards(ctx context.Context, filter bson.M) (int, error) {
+	cursor, err := gameCardCollection.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	indexed := 0
+	for cursor.Next(ctx) {
+		var gameCard models.GameCard
+		if err := cursor.Decode(&gameCard); err != nil {
+			return indexed, err
+		}
+		indexGameCard(ctx, gameCard)
+		indexed++
+	}
+	return indexed, cursor.Err()
+}
+
+// ReindexSearch перестраивает поисковый индекс карточек и антикафе,
+// например для данных, созданных до появления поиска
+func ReindexSearch(ctx context.Context) (int, error) {
+	indexed, err := reindexGameCards(ctx, bson.M{})
+	if err != nil {
+		return indexed, err
+	}
+
+	cursor, err := anticafeCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return indexed, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var anticafe models.AnticafeModel
+		if err := cursor.Decode(&anticafe); err != nil {
+			return indexed, err
+		}
+		indexAnticafe(ctx, anticafe)
+		indexed++
+	}
+	return indexed, cursor.Err()
+}
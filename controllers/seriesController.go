@@ -0,0 +1,533 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"oiynlike/database"
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var seriesCollection *mongo.Collection = database.OpenCollection("game_series")
+
+// defaultSeriesHorizon — на сколько вперёд заранее создаются повторения серии
+const defaultSeriesHorizon = 28 * 24 * time.Hour
+
+// seriesHorizon читает горизонт генерации из SERIES_HORIZON_DAYS
+func seriesHorizon() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("SERIES_HORIZON_DAYS"))
+	if err != nil || days <= 0 {
+		return defaultSeriesHorizon
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// openOccurrenceStatuses — статусы повторений, которые ещё не начались и не закрыты
+var openOccurrenceStatuses = bson.A{models.GameCardStatusActive, models.GameCardStatusFull}
+
+// seriesDuration — длительность каждого повторения серии
+func seriesDuration(series models.GameSeries) time.Duration {
+	if series.DurationMinutes > 0 {
+		return time.Duration(series.DurationMinutes) * time.Minute
+	}
+	return defaultGameDuration
+}
+
+// occurrenceFromSeries собирает карточку-повторение серии на время at
+func occurrenceFromSeries(series models.GameSeries, at time.Time) models.GameCard {
+	now := time.Now()
+	return models.GameCard{
+		HostUser:       series.HostUser,
+		Title:          series.Title,
+		Description:    series.Description,
+		City:           series.City,
+		CoverURL:       series.CoverURL,
+		Category:       series.Category,
+		MaxPlayers:     series.MaxPlayers,
+		MinPlayers:     series.MinPlayers,
+		Status:         models.GameCardStatusActive,
+		JoinPolicy:     series.JoinPolicy,
+		MatchedPlayers: []models.MatchedPlayer{},
+		Waitlist:       []models.MatchedPlayer{},
+		StatusHistory: []models.StatusChange{{
+			To:        models.GameCardStatusActive,
+			Actor:     helper.StatusActorSystem,
+			Reason:    "generated from series",
+			ChangedAt: now,
+		}},
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		ScheduledTime: at,
+		EndTime:       at.Add(seriesDuration(series)),
+		TimeZone:      series.TimeZone,
+		SeriesID:      series.ID,
+	}
+}
+
+// generateSeriesOccurrences создаёт недостающие повторения серии до горизонта генерации.
+// Повторение ищется по series_id и scheduled_time, поэтому повторный запуск (в том числе
+// после отмены отдельного повторения) не создаёт дубликатов.
+func generateSeriesOccurrences(ctx context.Context, series models.GameSeries) (int, error) {
+	if series.Status != models.SeriesStatusActive {
+		return 0, nil
+	}
+
+	rule, err := helper.ParseRRule(series.Recurrence)
+	if err != nil {
+		return 0, err
+	}
+
+	location, err := helper.LoadTimeZone(series.TimeZone)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	until := now.Add(seriesHorizon())
+
+	// Повторения считаются в поясе серии, чтобы местное время начала не сдвигалось при переходе на летнее время
+	created := 0
+	for _, at := range rule.Occurrences(series.StartTime.In(location), until) {
+		if !at.After(now) || !at.After(series.GeneratedUntil) {
+			continue
+		}
+
+		filter := bson.M{"series_id": series.ID, "scheduled_time": at}
+		update := bson.M{"$setOnInsert": occurrenceFromSeries(series, at)}
+
+		result, err := gameCardCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		if err != nil {
+			return created, fmt.Errorf("error creating series occurrence: %v", err)
+		}
+		if result.UpsertedCount > 0 {
+			created++
+			if _, err := reindexGameCards(ctx, bson.M{"_id": result.UpsertedID}); err != nil {
+				return created, err
+			}
+		}
+	}
+
+	_, err = seriesCollection.UpdateOne(ctx,
+		bson.M{"_id": series.ID},
+		bson.M{"$set": bson.M{"generated_until": until}},
+	)
+	if err != nil {
+		return created, fmt.Errorf("error updating series: %v", err)
+	}
+
+	return created, nil
+}
+
+// GenerateAllSeriesOccurrences продлевает все активные серии до горизонта генерации
+func GenerateAllSeriesOccurrences(ctx context.Context) error {
+	cursor, err := seriesCollection.Find(ctx, bson.M{"status": models.SeriesStatusActive})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var seriesList []models.GameSeries
+	if err := cursor.All(ctx, &seriesList); err != nil {
+		return err
+	}
+
+	for _, series := range seriesList {
+		if _, err := generateSeriesOccurrences(ctx, series); err != nil {
+			log.Printf("Error generating occurrences of series %s: %v", series.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// hostSeries загружает серию из параметра пути и проверяет, что запрос делает её хост
+func hostSeries(c *gin.Context, ctx context.Context) (models.GameSeries, bool) {
+	var series models.GameSeries
+
+	seriesID, err := primitive.ObjectIDFromHex(c.Param("seriesID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Id is incorrect"})
+		return series, false
+	}
+
+	err = seriesCollection.FindOne(ctx, bson.M{"_id": seriesID}).Decode(&series)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Series not found"})
+		return series, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving series data"})
+		return series, false
+	}
+
+	if err := helper.MatchUserTypeToUid(c, series.HostUser.UserID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the host can manage the series"})
+		return series, false
+	}
+
+	return series, true
+}
+
+func CreateSeries() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, _ := c.Get("uid")
+		userIDString := fmt.Sprintf("%v", userID)
+
+		var series models.GameSeries
+		if err := c.BindJSON(&series); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(series); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if _, err := helper.ParseRRule(series.Recurrence); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if series.TimeZone == "" {
+			series.TimeZone = helper.DefaultTimeZone(series.City)
+		}
+		if _, err := helper.LoadTimeZone(series.TimeZone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !series.StartTime.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_time must be in the future"})
+			return
+		}
+		if series.DurationMinutes == 0 {
+			series.DurationMinutes = int(defaultGameDuration / time.Minute)
+		}
+
+		user, err := GetUserByID(c, userIDString)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data"})
+			return
+		}
+
+		series.ID = primitive.NewObjectID()
+		series.HostUser = models.HostUser{
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			UserID:    userIDString,
+			PhotoURL:  user.PhotoURL,
+			City:      user.City,
+		}
+		if series.JoinPolicy == "" {
+			series.JoinPolicy = models.JoinPolicyOpen
+		}
+		// Серия проходит модерацию один раз, после чего повторения создаются сразу активными
+		series.Status = models.SeriesStatusModeration
+		series.GeneratedUntil = time.Time{}
+		series.CreatedAt = time.Now()
+		series.UpdatedAt = time.Now()
+
+		if _, err := seriesCollection.InsertOne(ctx, series); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"data": series})
+	}
+}
+
+func GetSeries() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		seriesID, err := primitive.ObjectIDFromHex(c.Param("seriesID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Id is incorrect"})
+			return
+		}
+
+		var series models.GameSeries
+		err = seriesCollection.FindOne(ctx, bson.M{"_id": seriesID}).Decode(&series)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Series not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving series data"})
+			return
+		}
+
+		cursor, err := gameCardCollection.Find(ctx,
+			bson.M{"series_id": seriesID, "scheduled_time": bson.M{"$gte": time.Now()}},
+			options.Find().SetSort(bson.D{{Key: "scheduled_time", Value: 1}}),
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		occurrences := []models.GameCard{}
+		if err := cursor.All(ctx, &occurrences); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": series, "occurrences": occurrences})
+	}
+}
+
+// UpdateSeries изменяет всю серию: шаблон и ещё не начавшиеся повторения, кроме
+// изменённых отдельно. Отдельное повторение изменяется через PATCH /gamecards/:gameCardID.
+func UpdateSeries() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		series, ok := hostSeries(c, ctx)
+		if !ok {
+			return
+		}
+
+		var updateData models.GameSeries
+		if err := c.ShouldBindJSON(&updateData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		// Новое правило повторения — это новая серия: старую нужно отменить
+		if updateData.Recurrence != "" || !updateData.StartTime.IsZero() || updateData.TimeZone != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Recurrence cannot be changed, cancel the series and create a new one"})
+			return
+		}
+		if updateData.JoinPolicy != "" && updateData.JoinPolicy != models.JoinPolicyOpen && updateData.JoinPolicy != models.JoinPolicyApproval {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "join_policy must be open or approval"})
+			return
+		}
+
+		// Лимиты игроков проверяются вместе с текущими значениями серии
+		minPlayers, maxPlayers := series.MinPlayers, series.MaxPlayers
+		if updateData.MinPlayers != 0 {
+			minPlayers = updateData.MinPlayers
+		}
+		if updateData.MaxPlayers != 0 {
+			maxPlayers = updateData.MaxPlayers
+		}
+		if minPlayers < 0 || maxPlayers < 0 || minPlayers > maxPlayers {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_players must be positive and not greater than max_players"})
+			return
+		}
+
+		fields := bson.M{}
+		if updateData.Title != "" {
+			fields["title"] = updateData.Title
+		}
+		if updateData.Description != "" {
+			fields["description"] = updateData.Description
+		}
+		if updateData.City != "" {
+			fields["city"] = updateData.City
+		}
+		if updateData.CoverURL != "" {
+			fields["cover_url"] = updateData.CoverURL
+		}
+		if updateData.Category != "" {
+			fields["category"] = updateData.Category
+		}
+		if updateData.MaxPlayers != 0 {
+			fields["max_players"] = updateData.MaxPlayers
+		}
+		if updateData.MinPlayers != 0 {
+			fields["min_players"] = updateData.MinPlayers
+		}
+		if updateData.JoinPolicy != "" {
+			fields["join_policy"] = updateData.JoinPolicy
+		}
+		fields["updated_at"] = time.Now()
+
+		if _, err := seriesCollection.UpdateOne(ctx, bson.M{"_id": series.ID}, bson.M{"$set": fields}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating series"})
+			return
+		}
+
+		filter := bson.M{
+			"series_id":       series.ID,
+			"series_override": bson.M{"$ne": true},
+			"status":          bson.M{"$in": openOccurrenceStatuses},
+			"scheduled_time":  bson.M{"$gt": time.Now()},
+		}
+		// Повторения, в которых игроков больше нового max_players, сохраняют прежние лимиты
+		if updateData.MaxPlayers != 0 {
+			filter["$expr"] = bson.M{"$lte": bson.A{
+				bson.M{"$size": bson.M{"$ifNull": bson.A{"$matched_players", bson.A{}}}},
+				updateData.MaxPlayers,
+			}}
+		}
+
+		result, err := gameCardCollection.UpdateMany(ctx, filter, bson.M{"$set": fields})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating series occurrences"})
+			return
+		}
+
+		// Новые лимиты могут освободить места для листа ожидания, закрыть набор или дать кворум
+		if updateData.MinPlayers != 0 || updateData.MaxPlayers != 0 {
+			cursor, err := gameCardCollection.Find(ctx, filter)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			var occurrences []models.GameCard
+			if err := cursor.All(ctx, &occurrences); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			for _, occurrence := range occurrences {
+				if err := syncRoster(c, &occurrence); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+			}
+		}
+
+		if updateData.Title != "" || updateData.Description != "" {
+			if _, err := reindexGameCards(ctx, filter); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Series updated successfully", "updated_occurrences": result.ModifiedCount})
+	}
+}
+
+// CancelSeries отменяет серию и все её ещё не начавшиеся повторения.
+// Отдельное повторение отменяется через POST /gamecards/:gameCardID/cancel.
+func CancelSeries() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, _ := c.Get("uid")
+		userIDString := fmt.Sprintf("%v", userID)
+
+		series, ok := hostSeries(c, ctx)
+		if !ok {
+			return
+		}
+
+		var request CancelGameCardRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, err := seriesCollection.UpdateOne(ctx,
+			bson.M{"_id": series.ID, "status": bson.M{"$ne": models.SeriesStatusCancelled}},
+			bson.M{"$set": bson.M{"status": models.SeriesStatusCancelled, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error cancelling series"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Series is already cancelled"})
+			return
+		}
+
+		cursor, err := gameCardCollection.Find(ctx, bson.M{
+			"series_id":      series.ID,
+			"status":         bson.M{"$in": openOccurrenceStatuses},
+			"scheduled_time": bson.M{"$gt": time.Now()},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var occurrences []models.GameCard
+		if err := cursor.All(ctx, &occurrences); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		cancelled := 0
+		for _, occurrence := range occurrences {
+			err := transitionGameCardStatus(ctx, &occurrence, models.GameCardStatusCancelled, helper.StatusActorHost, userIDString, request.Reason, nil)
+			if errors.Is(err, ErrGameCardStatusChanged) {
+				continue
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if err := onGameCardCancelled(ctx, occurrence, request.Reason); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			cancelled++
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Series cancelled successfully", "cancelled_occurrences": cancelled})
+	}
+}
+
+// ModerateSeries — решение модератора по серии: "active" запускает генерацию повторений
+func ModerateSeries() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		seriesID, err := primitive.ObjectIDFromHex(c.Param("seriesID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Id is incorrect"})
+			return
+		}
+
+		status := c.PostForm("status")
+		if status != models.SeriesStatusActive && status != models.SeriesStatusRejected {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be active or rejected"})
+			return
+		}
+
+		var series models.GameSeries
+		err = seriesCollection.FindOneAndUpdate(ctx,
+			bson.M{"_id": seriesID, "status": models.SeriesStatusModeration},
+			bson.M{"$set": bson.M{"status": status, "updated_at": time.Now()}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&series)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusConflict, gin.H{"error": "Series not found or already moderated"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating series"})
+			return
+		}
+
+		created, err := generateSeriesOccurrences(ctx, series)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Series status updated successfully", "created_occurrences": created})
+	}
+}
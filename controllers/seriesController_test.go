@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"oiynlike/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSeriesOccurrenceAcceptsWaitlist(t *testing.T) {
+	requireMongo(t)
+
+	ctx := context.Background()
+	series := models.GameSeries{
+		ID:          primitive.NewObjectID(),
+		HostUser:    models.HostUser{UserID: primitive.NewObjectID().Hex()},
+		Title:       "Test series",
+		Description: "Test series",
+		City:        "Almaty",
+		MinPlayers:  1,
+		MaxPlayers:  1,
+		JoinPolicy:  models.JoinPolicyOpen,
+		Recurrence:  "FREQ=WEEKLY;COUNT=1",
+		StartTime:   time.Now().Add(24 * time.Hour).Truncate(time.Second),
+		TimeZone:    "Asia/Almaty",
+		Status:      models.SeriesStatusActive,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if _, err := seriesCollection.InsertOne(ctx, series); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		seriesCollection.DeleteOne(context.Background(), bson.M{"_id": series.ID})
+		gameCardCollection.DeleteMany(context.Background(), bson.M{"series_id": series.ID})
+	})
+
+	if created, err := generateSeriesOccurrences(ctx, series); err != nil || created != 1 {
+		t.Fatalf("expected one occurrence, got %d (%v)", created, err)
+	}
+
+	var occurrence models.GameCard
+	if err := gameCardCollection.FindOne(ctx, bson.M{"series_id": series.ID}).Decode(&occurrence); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if _, err := AddPlayerToGameCard(c, insertTestUser(t, true).UserId, occurrence.ID); err != nil {
+		t.Fatalf("join failed: %v", err)
+	}
+
+	position, err := AddPlayerToWaitlist(c, insertTestUser(t, true).UserId, occurrence.ID)
+	if err != nil {
+		t.Fatalf("waitlist failed: %v", err)
+	}
+	if position != 1 {
+		t.Fatalf("expected waitlist position 1, got %d", position)
+	}
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	helper "oiynlike/helpers"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func GetSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		sessions, err := helper.GetUserSessions(ctx, c.GetString("uid"))
+		if err != nil {
+			log.Printf("Error retrieving sessions: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving sessions"})
+			return
+		}
+
+		// Помечаем сессию, с которой пришёл запрос
+		currentSession := c.GetString("token_family")
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID.Hex() == currentSession
+		}
+
+		c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+	}
+}
+
+func RevokeSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		sessionID := c.Param("id")
+		if _, err := primitive.ObjectIDFromHex(sessionID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID format"})
+			return
+		}
+
+		userID := c.GetString("uid")
+
+		sessions, err := helper.GetUserSessions(ctx, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving sessions"})
+			return
+		}
+
+		found := false
+		for _, session := range sessions {
+			if session.ID.Hex() == sessionID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+
+		// Отзываем refresh-токены сессии, вместе с ней перестают приниматься и её токены доступа
+		if err := helper.RevokeTokenFamily(ctx, userID, sessionID); err != nil {
+			log.Printf("Error revoking session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking session"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Session revoked successfully"})
+	}
+}
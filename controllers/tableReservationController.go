@@ -0,0 +1,468 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"oiynlike/database"
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var tableScheduleCollection *mongo.Collection = database.OpenCollection("table_schedules")
+
+var (
+	ErrTableNotFound = errors.New("table not found")
+	ErrTableBooked   = errors.New("table is already booked for this time")
+	ErrTableTooSmall = errors.New("table has fewer seats than max_players")
+	ErrTableReserved = errors.New("gameCard already has a reserved table")
+	ErrTableInUse    = errors.New("table has upcoming reservations")
+)
+
+// tableReservationRetention — сколько хранятся брони прошедших игр
+const tableReservationRetention = 30 * 24 * time.Hour
+
+// reservableStatuses — статусы карточек, для которых можно бронировать стол
+var reservableStatuses = bson.A{
+	models.GameCardStatusDraft,
+	models.GameCardStatusModeration,
+	models.GameCardStatusActive,
+	models.GameCardStatusFull,
+}
+
+type ReserveTableRequest struct {
+	TableID string `json:"table_id" binding:"required"`
+}
+
+// DayReservation — бронь стола в расписании антикафе на день
+type DayReservation struct {
+	TableID        primitive.ObjectID `json:"table_id"`
+	TableName      string             `json:"table_name"`
+	Seats          int                `json:"seats"`
+	GameCardID     primitive.ObjectID `json:"gamecard_id"`
+	GameCardTitle  string             `json:"gamecard_title"`
+	GameCardStatus string             `json:"gamecard_status"`
+	HostUserID     string             `json:"host_user_id"`
+	StartTime      time.Time          `json:"start_time"`
+	EndTime        time.Time          `json:"end_time"`
+}
+
+// findTable ищет стол антикафе по ID
+func findTable(anticafe models.AnticafeModel, tableID primitive.ObjectID) (models.Table, bool) {
+	for _, table := range anticafe.Tables {
+		if table.ID == tableID {
+			return table, true
+		}
+	}
+	return models.Table{}, false
+}
+
+// normalizeTables проверяет столы антикафе и выдаёт ID новым столам.
+// ID существующих столов сохраняются, неизвестные ID отклоняются.
+func normalizeTables(tables []models.Table, existing []models.Table) error {
+	known := make(map[primitive.ObjectID]bool, len(existing))
+	for _, table := range existing {
+		known[table.ID] = true
+	}
+
+	seen := make(map[primitive.ObjectID]bool, len(tables))
+	for i := range tables {
+		if tables[i].Name == "" {
+			return fmt.Errorf("table name is required")
+		}
+		if tables[i].Seats <= 0 {
+			return fmt.Errorf("table seats must be positive")
+		}
+		if tables[i].ID.IsZero() {
+			tables[i].ID = primitive.NewObjectID()
+		} else if !known[tables[i].ID] {
+			return fmt.Errorf("unknown table %s", tables[i].ID.Hex())
+		}
+		if seen[tables[i].ID] {
+			return fmt.Errorf("duplicate table %s", tables[i].ID.Hex())
+		}
+		seen[tables[i].ID] = true
+	}
+	return nil
+}
+
+// checkRemovedTables не даёт удалить из антикафе столы с предстоящими бронями
+func checkRemovedTables(ctx context.Context, tables []models.Table, existing []models.Table) error {
+	kept := make(map[primitive.ObjectID]bool, len(tables))
+	for _, table := range tables {
+		kept[table.ID] = true
+	}
+
+	removed := bson.A{}
+	for _, table := range existing {
+		if !kept[table.ID] {
+			removed = append(removed, table.ID)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	count, err := tableScheduleCollection.CountDocuments(ctx, bson.M{
+		"_id":                   bson.M{"$in": removed},
+		"reservations.end_time": bson.M{"$gt": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTableInUse
+	}
+	return nil
+}
+
+// reserveTable добавляет бронь в расписание стола одним условным обновлением:
+// документ обновляется, только если у стола нет брони, пересекающейся с новой.
+// Если документ есть, но условие не выполнено, upsert пытается вставить документ
+// с тем же _id и получает ошибку дубликата ключа — значит, время занято.
+func reserveTable(ctx context.Context, anticafeID, tableID primitive.ObjectID, reservation models.TableReservation) error {
+	filter := bson.M{
+		"_id": tableID,
+		"reservations": bson.M{"$not": bson.M{"$elemMatch": bson.M{
+			"start_time": bson.M{"$lt": reservation.EndTime},
+			"end_time":   bson.M{"$gt": reservation.StartTime},
+		}}},
+	}
+	update := bson.M{
+		"$setOnInsert": bson.M{"anticafe_id": anticafeID},
+		"$push":        bson.M{"reservations": reservation},
+	}
+
+	// Первые брони стола могут одновременно создавать его расписание: проигравшая вставка
+	// получает дубликат ключа, хотя время свободно, поэтому она повторяется уже как обновление
+	for attempt := 0; ; attempt++ {
+		_, err := tableScheduleCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		if mongo.IsDuplicateKeyError(err) {
+			if attempt == 0 {
+				continue
+			}
+			return ErrTableBooked
+		}
+		if err != nil {
+			return fmt.Errorf("error reserving table: %v", err)
+		}
+		return nil
+	}
+}
+
+// releaseTable удаляет бронь карточки из расписания стола
+func releaseTable(ctx context.Context, tableID, gameCardID primitive.ObjectID) error {
+	_, err := tableScheduleCollection.UpdateOne(ctx,
+		bson.M{"_id": tableID},
+		bson.M{"$pull": bson.M{"reservations": bson.M{"gamecard_id": gameCardID}}},
+	)
+	if err != nil {
+		return fmt.Errorf("error releasing table: %v", err)
+	}
+	return nil
+}
+
+// releaseGameCardTable снимает бронь стола карточки, если она есть
+func releaseGameCardTable(ctx context.Context, gameCard *models.GameCard) error {
+	if gameCard.TableID.IsZero() {
+		return nil
+	}
+	if err := releaseTable(ctx, gameCard.TableID, gameCard.ID); err != nil {
+		return err
+	}
+
+	_, err := gameCardCollection.UpdateOne(ctx,
+		bson.M{"_id": gameCard.ID, "table_id": gameCard.TableID},
+		bson.M{"$unset": bson.M{"table_id": ""}},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating gameCard: %v", err)
+	}
+	gameCard.TableID = primitive.NilObjectID
+	return nil
+}
+
+// hostGameCardForTable загружает карточку из параметра gameCardID и проверяет, что её изменяет хост
+func hostGameCardForTable(c *gin.Context, ctx context.Context) (models.GameCard, bool) {
+	gameCardID, err := primitive.ObjectIDFromHex(c.Param("gameCardID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Id is incorrect"})
+		return models.GameCard{}, false
+	}
+
+	gameCard, err := getGameCardByID(ctx, gameCardID)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "GameCard not found"})
+		return gameCard, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return gameCard, false
+	}
+
+	if err := helper.MatchUserTypeToUid(c, gameCard.HostUser.UserID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the host can manage the table reservation"})
+		return gameCard, false
+	}
+	return gameCard, true
+}
+
+// ReserveTable бронирует стол антикафе карточки на время игры
+func ReserveTable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request ReserveTableRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		tableID, err := primitive.ObjectIDFromHex(request.TableID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid table ID format"})
+			return
+		}
+
+		gameCard, ok := hostGameCardForTable(c, ctx)
+		if !ok {
+			return
+		}
+
+		if gameCard.AnticafeID.IsZero() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "gameCard is not linked to an anticafe"})
+			return
+		}
+		if !gameCard.TableID.IsZero() {
+			c.JSON(http.StatusConflict, gin.H{"error": ErrTableReserved.Error()})
+			return
+		}
+		reservable := false
+		for _, status := range reservableStatuses {
+			if gameCard.Status == status {
+				reservable = true
+				break
+			}
+		}
+		if !reservable {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("cannot reserve a table for a %s gameCard", gameCard.Status)})
+			return
+		}
+
+		var anticafe models.AnticafeModel
+		err = anticafeCollection.FindOne(ctx, bson.M{"_id": gameCard.AnticafeID}).Decode(&anticafe)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": ErrVenueNotFound.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		table, ok := findTable(anticafe, tableID)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": ErrTableNotFound.Error()})
+			return
+		}
+		if table.Seats < gameCard.MaxPlayers {
+			c.JSON(http.StatusConflict, gin.H{"error": ErrTableTooSmall.Error()})
+			return
+		}
+
+		reservation := models.TableReservation{
+			GameCardID: gameCard.ID,
+			HostUserID: gameCard.HostUser.UserID,
+			StartTime:  gameCard.ScheduledTime,
+			EndTime:    gameCard.EndTime,
+			CreatedAt:  time.Now(),
+		}
+		err = reserveTable(ctx, anticafe.ID, table.ID, reservation)
+		if errors.Is(err, ErrTableBooked) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Бронь закрепляется за карточкой, только если её время, антикафе и статус
+		// не изменились параллельно; иначе бронь снимается
+		result, err := gameCardCollection.UpdateOne(ctx,
+			bson.M{
+				"_id":            gameCard.ID,
+				"table_id":       bson.M{"$exists": false},
+				"anticafe_id":    gameCard.AnticafeID,
+				"scheduled_time": gameCard.ScheduledTime,
+				"end_time":       gameCard.EndTime,
+				"status":         bson.M{"$in": reservableStatuses},
+			},
+			bson.M{"$set": bson.M{"table_id": table.ID, "updated_at": time.Now()}},
+		)
+		if err == nil && result.MatchedCount == 0 {
+			err = ErrGameCardStatusChanged
+		}
+		if err != nil {
+			if releaseErr := releaseTable(ctx, table.ID, gameCard.ID); releaseErr != nil {
+				err = releaseErr
+			}
+			if errors.Is(err, ErrGameCardStatusChanged) {
+				c.JSON(http.StatusConflict, gin.H{"error": "gameCard was changed concurrently, try again"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Table reserved", "table": table, "data": reservation})
+	}
+}
+
+// ReleaseTable снимает бронь стола карточки
+func ReleaseTable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		gameCard, ok := hostGameCardForTable(c, ctx)
+		if !ok {
+			return
+		}
+		if gameCard.TableID.IsZero() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "gameCard has no reserved table"})
+			return
+		}
+
+		if err := releaseGameCardTable(ctx, &gameCard); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Table released"})
+	}
+}
+
+// GetAnticafeReservations — брони столов антикафе на день для персонала заведения.
+// День (date=YYYY-MM-DD, по умолчанию сегодня) считается в поясе time_zone, по умолчанию — в поясе антикафе.
+func GetAnticafeReservations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		anticafeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid anticafe ID format"})
+			return
+		}
+
+		var anticafe models.AnticafeModel
+		err = anticafeCollection.FindOne(ctx, bson.M{"_id": anticafeID}).Decode(&anticafe)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Anticafe not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Расписание видят только владелец антикафе и администраторы
+		if err := helper.MatchUserTypeToUid(c, anticafe.OwnerID); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		timeZone := c.DefaultQuery("time_zone", anticafeTimeZone(anticafe))
+		location, err := helper.LoadTimeZone(timeZone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		day := time.Now().In(location)
+		if date := c.Query("date"); date != "" {
+			day, err = time.ParseInLocation("2006-01-02", date, location)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "date must be in YYYY-MM-DD format"})
+				return
+			}
+		}
+		dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, location)
+		dayEnd := dayStart.AddDate(0, 0, 1)
+
+		// В выдачу попадают брони, пересекающиеся с днём, в том числе ночные с предыдущего дня
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"anticafe_id": anticafeID}}},
+			{{Key: "$unwind", Value: "$reservations"}},
+			{{Key: "$match", Value: bson.M{
+				"reservations.start_time": bson.M{"$lt": dayEnd},
+				"reservations.end_time":   bson.M{"$gt": dayStart},
+			}}},
+			{{Key: "$lookup", Value: bson.M{
+				"from":         "gamecards",
+				"localField":   "reservations.gamecard_id",
+				"foreignField": "_id",
+				"as":           "gamecard",
+			}}},
+			{{Key: "$sort", Value: bson.D{{Key: "reservations.start_time", Value: 1}}}},
+		}
+
+		cursor, err := tableScheduleCollection.Aggregate(ctx, pipeline)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var rows []struct {
+			TableID     primitive.ObjectID      `bson:"_id"`
+			Reservation models.TableReservation `bson:"reservations"`
+			GameCard    []models.GameCard       `bson:"gamecard"`
+		}
+		if err := cursor.All(ctx, &rows); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		reservations := make([]DayReservation, 0, len(rows))
+		for _, row := range rows {
+			reservation := DayReservation{
+				TableID:    row.TableID,
+				GameCardID: row.Reservation.GameCardID,
+				HostUserID: row.Reservation.HostUserID,
+				StartTime:  row.Reservation.StartTime,
+				EndTime:    row.Reservation.EndTime,
+			}
+			if table, ok := findTable(anticafe, row.TableID); ok {
+				reservation.TableName = table.Name
+				reservation.Seats = table.Seats
+			}
+			if len(row.GameCard) > 0 {
+				reservation.GameCardTitle = row.GameCard[0].Title
+				reservation.GameCardStatus = row.GameCard[0].Status
+			}
+			reservations = append(reservations, reservation)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"date": dayStart.Format("2006-01-02"), "time_zone": timeZone, "data": reservations})
+	}
+}
+
+// PurgePastTableReservations удаляет из расписаний столов брони давно прошедших игр
+func PurgePastTableReservations(ctx context.Context) error {
+	_, err := tableScheduleCollection.UpdateMany(ctx,
+		bson.M{},
+		bson.M{"$pull": bson.M{"reservations": bson.M{"end_time": bson.M{"$lt": time.Now().Add(-tableReservationRetention)}}}},
+	)
+	return err
+}
@@ -2,13 +2,16 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"oiynlike/database"
 	helper "oiynlike/helpers"
 
 	"oiynlike/models"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +25,7 @@ import (
 
 var userCollection *mongo.Collection = database.OpenCollection("users")
 var validate = validator.New()
+var loginGuard = helper.NewLoginGuard(helper.NewMongoLoginAttemptStore(database.OpenCollection("login_attempts")))
 
 func HashPassword(providedPassword string) string {
 	hashPassword, err := bcrypt.GenerateFromPassword([]byte(providedPassword), 14)
@@ -69,6 +73,12 @@ func Signup() gin.HandlerFunc {
 			return
 		}
 
+		// Роль не принимается от клиента: новые пользователи всегда USER
+		user.UserType = helper.RoleUser
+		user.EmailVerified = false
+		user.Identities = nil
+		user.MFA = models.MFA{}
+
 		validationErr := validate.Struct(user)
 		if validationErr != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
@@ -123,23 +133,26 @@ func Signup() gin.HandlerFunc {
 
 		fmt.Printf("Email: %s, FirstName: %s, LastName: %s, UserType: %s, UserID: %s\n", user.Email, user.FirstName, user.LastName, user.UserType, user.UserId)
 
-		token, refreshToken, err := helper.GenerateAllTokens(user.Email, user.FirstName, user.LastName, user.UserType, user.UserId)
-		if err != nil {
-			log.Printf("Error generating JWT: %v", err)
-			msg := fmt.Sprint("couldn't generate jwt")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-			return
-		}
-		user.Token = token
-		user.RefreshToken = refreshToken
+		// Токены выдаются при входе: у каждого устройства своя сессия
+		user.Token = ""
+		user.RefreshToken = ""
 
 		resultInsertionNumber, insertErr := userCollection.InsertOne(ctx, user)
+		if mongo.IsDuplicateKeyError(insertErr) {
+			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+			return
+		}
 		if insertErr != nil {
 			msg := fmt.Sprint("user hasn't created ")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
 
+		// Письмо можно запросить повторно, поэтому ошибка отправки не отменяет регистрацию
+		if err := sendVerificationEmail(ctx, user); err != nil {
+			log.Printf("Error sending verification email: %v", err)
+		}
+
 		c.JSON(http.StatusOK, resultInsertionNumber)
 	}
 }
@@ -160,33 +173,56 @@ func Signup() gin.HandlerFunc {
 func Login() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
 		var user models.User
 		var foundUser models.User
 		if err := c.BindJSON(&user); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Защита от подбора пароля
+		if err := loginGuard.Check(ctx, user.Email, c.ClientIP()); err != nil {
+			abortLoginBlocked(c, err)
+			return
 		}
 
 		err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&foundUser)
-		defer cancel()
 
 		if err != nil {
+			recordLoginFailure(ctx, user.Email, c.ClientIP())
 			c.JSON(http.StatusBadRequest, gin.H{"error": "email or password is incorrect"})
 			return
 		}
 
 		passwordIsValid, msg := VerifyPassword(user.Password, foundUser.Password)
-		defer cancel()
 		if passwordIsValid != true {
+			recordLoginFailure(ctx, user.Email, c.ClientIP())
 			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 			return
 		}
 
+		if err := loginGuard.RecordSuccess(ctx, user.Email); err != nil {
+			log.Printf("Error resetting login attempts: %v", err)
+		}
+
 		if foundUser.Email == "" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		}
 
-		token, refreshToken, _ := helper.GenerateAllTokens(foundUser.Email, foundUser.FirstName, foundUser.LastName, foundUser.UserType, foundUser.UserId)
-		helper.UpdateAllTokens(token, refreshToken, foundUser.UserId)
+		// С включённой 2FA токены выдаются только после проверки кода
+		if foundUser.MFA.Enabled {
+			respondMFARequired(c, foundUser)
+			return
+		}
+
+		token, refreshToken, err := startSession(c, ctx, foundUser, false)
+		if err != nil {
+			log.Printf("Error starting session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't generate jwt"})
+			return
+		}
 		err = userCollection.FindOne(ctx, bson.M{"user_id": foundUser.UserId}).Decode(&foundUser)
 
 		if err != nil {
@@ -194,12 +230,198 @@ func Login() gin.HandlerFunc {
 			return
 		}
 
+		foundUser.Token = token
+		foundUser.RefreshToken = refreshToken
 		c.JSON(http.StatusOK, foundUser)
 
 	}
 
 }
 
+// startSession выдаёт пару токенов для нового входа и заводит сессию текущего устройства
+func startSession(c *gin.Context, ctx context.Context, user models.User, mfa bool) (string, string, error) {
+	generate := helper.GenerateAllTokens
+	if mfa {
+		generate = helper.GenerateAllTokensWithMFA
+	}
+
+	token, refreshToken, err := generate(user.Email, user.FirstName, user.LastName, user.UserType, user.UserId)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := helper.CreateSession(ctx, refreshToken, c.Request.UserAgent(), c.ClientIP()); err != nil {
+		return "", "", err
+	}
+
+	if err := helper.UpdateAllTokens(ctx, token, refreshToken, user.UserId); err != nil {
+		if err := helper.DeleteSession(ctx, refreshToken); err != nil {
+			log.Printf("Error deleting session: %v", err)
+		}
+		return "", "", err
+	}
+	return token, refreshToken, nil
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// @Summary Refresh tokens
+// @Description Exchanges a refresh token for a new access/refresh token pair. The presented refresh token is invalidated; replaying an already used one revokes its whole token family
+// @ID user-refresh
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest true "Refresh token"
+// @Success 200 {object} map[string]string "New token pair"
+// @Failure 400 {object} ErrorResponse "Bad Request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /users/refresh [post]
+func RefreshToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request RefreshRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+			return
+		}
+
+		token, refreshToken, err := helper.RotateRefreshToken(ctx, request.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			if errors.Is(err, helper.ErrRefreshTokenInvalid) || errors.Is(err, helper.ErrRefreshTokenReused) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("Error rotating refresh token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't refresh tokens"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"token": token, "refresh_token": refreshToken})
+	}
+}
+
+// @Summary User logout
+// @Description Revokes the access token of the current device together with its refresh token family
+// @ID user-logout
+// @Tags Users
+// @Produce json
+// @Success 200 {object} map[string]string "Logged out"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /user/logout [post]
+func Logout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID := c.GetString("uid")
+		tokenID := c.GetString("jti")
+		family := c.GetString("token_family")
+		exp, _ := c.Get("token_exp")
+		expUnix, _ := exp.(float64)
+
+		// Старые токены выданы без jti, отозвать их по отдельности нельзя
+		if tokenID == "" {
+			if err := helper.RevokeAllTokens(ctx, userID); err != nil {
+				log.Printf("Error revoking tokens: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't log out"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"msg": "logged out successfully"})
+			return
+		}
+
+		if err := helper.RevokeToken(ctx, tokenID, userID, time.Unix(int64(expUnix), 0)); err != nil {
+			log.Printf("Error revoking token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't log out"})
+			return
+		}
+
+		if family != "" {
+			if err := helper.RevokeTokenFamily(ctx, userID, family); err != nil {
+				log.Printf("Error revoking refresh tokens: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't log out"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "logged out successfully"})
+	}
+}
+
+// @Summary User logout from all devices
+// @Description Revokes every access and refresh token issued to the user
+// @ID user-logout-all
+// @Tags Users
+// @Produce json
+// @Success 200 {object} map[string]string "Logged out"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /user/logout/all [post]
+func LogoutAll() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID := c.GetString("uid")
+
+		if err := helper.RevokeAllTokens(ctx, userID); err != nil {
+			log.Printf("Error revoking tokens: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't log out"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "logged out from all devices successfully"})
+	}
+}
+
+// abortLoginBlocked отвечает на попытку входа, запрещённую LoginGuard
+func abortLoginBlocked(c *gin.Context, err error) {
+	var blocked *helper.LoginBlockedError
+	if errors.As(err, &blocked) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": blocked.Error()})
+		return
+	}
+	log.Printf("Error checking login attempts: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+}
+
+func recordLoginFailure(ctx context.Context, email string, ip string) {
+	if err := loginGuard.RecordFailure(ctx, email, ip); err != nil {
+		log.Printf("Error recording login failure: %v", err)
+	}
+}
+
+// admin
+func UnlockUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": c.Param("user_id")}).Decode(&user)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data"})
+			return
+		}
+
+		if err := loginGuard.Unlock(ctx, user.Email); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error unlocking user"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "User unlocked successfully"})
+	}
+}
+
 func updateProfile(ctx context.Context, userID string, updatedUser models.User) error {
 	objectID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrVenueNotFound = errors.New("anticafe not found")
+	// ErrVenueClosed — игра не укладывается в часы работы антикафе
+	ErrVenueClosed = errors.New("anticafe is closed at this time")
+)
+
+// venueFromAnticafe возвращает краткие сведения об антикафе для карточки
+func venueFromAnticafe(anticafe models.AnticafeModel) *models.Venue {
+	return &models.Venue{
+		ID:          anticafe.ID,
+		Title:       anticafe.Title,
+		Address:     anticafe.Address,
+		PhoneNumber: anticafe.PhoneNumber,
+		OpeningTime: anticafe.OpeningTime,
+		ClosingTime: anticafe.ClosingTime,
+		TimeZone:    anticafeTimeZone(anticafe),
+	}
+}
+
+// anticafeTimeZone возвращает пояс, в котором указаны часы работы антикафе
+func anticafeTimeZone(anticafe models.AnticafeModel) string {
+	if anticafe.TimeZone != "" {
+		return anticafe.TimeZone
+	}
+	return helper.DefaultTimeZone(anticafe.City)
+}
+
+// attachVenue проверяет, что игра проходит в часы работы антикафе gameCard.AnticafeID
+// (по местному времени антикафе), и копирует в карточку сведения о нём. Если у карточки нет своей точки, она берётся у антикафе.
+// Время карточки должно быть уже проверено normalizeSchedule.
+func attachVenue(ctx context.Context, gameCard *models.GameCard) error {
+	var anticafe models.AnticafeModel
+	err := anticafeCollection.FindOne(ctx, bson.M{"_id": gameCard.AnticafeID}).Decode(&anticafe)
+	if err == mongo.ErrNoDocuments {
+		return ErrVenueNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	location, err := helper.LoadTimeZone(anticafeTimeZone(anticafe))
+	if err != nil {
+		return err
+	}
+	err = helper.CheckOpeningHours(gameCard.ScheduledTime, gameCard.EndTime, anticafe.OpeningTime, anticafe.ClosingTime, location)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVenueClosed, err)
+	}
+
+	gameCard.Venue = venueFromAnticafe(anticafe)
+	if gameCard.Location == nil {
+		gameCard.Location = anticafe.Location
+	}
+	return nil
+}
+
+// isVenueError сообщает, что ошибка attachVenue вызвана данными запроса, а не базой
+func isVenueError(err error) bool {
+	return errors.Is(err, ErrVenueNotFound) || errors.Is(err, ErrVenueClosed)
+}
+
+// refreshGameCardVenues обновляет сведения об антикафе во всех привязанных к нему карточках
+func refreshGameCardVenues(ctx context.Context, anticafe models.AnticafeModel) error {
+	_, err := gameCardCollection.UpdateMany(ctx,
+		bson.M{"anticafe_id": anticafe.ID},
+		bson.M{"$set": bson.M{"venue": venueFromAnticafe(anticafe)}},
+	)
+	return err
+}
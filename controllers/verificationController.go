@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	helper "oiynlike/helpers"
+	"oiynlike/mailer"
+	"oiynlike/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	verifyEmailTokenTTL   = 24 * time.Hour
+	resetPasswordTokenTTL = time.Hour
+)
+
+type TokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// appURL возвращает адрес клиентского приложения для ссылок в письмах
+func appURL() string {
+	url := os.Getenv("APP_URL")
+	if url == "" {
+		url = "http://localhost:3000"
+	}
+	return url
+}
+
+func sendVerificationEmail(ctx context.Context, user models.User) error {
+	token, err := helper.GenerateActionToken(ctx, user.UserId, helper.ActionVerifyEmail, verifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Default().Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body:    fmt.Sprintf("Hi %s,\n\nconfirm your email by following the link:\n%s/verify-email?token=%s\n\nThe link is valid for 24 hours.", user.FirstName, appURL(), token),
+	})
+}
+
+// @Summary Verify email
+// @Description Confirms the user's email with a single-use token sent after signup
+// @ID user-verify-email
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param request body TokenRequest true "Verification token"
+// @Success 200 {object} map[string]string "Email verified"
+// @Failure 400 {object} ErrorResponse "Bad Request"
+// @Router /users/verify-email [post]
+func VerifyEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request TokenRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+			return
+		}
+
+		userID, err := helper.ConsumeActionToken(ctx, request.Token, helper.ActionVerifyEmail)
+		if err != nil {
+			if errors.Is(err, helper.ErrActionTokenInvalid) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("Error verifying email: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error verifying email"})
+			return
+		}
+
+		_, err = userCollection.UpdateOne(ctx,
+			bson.M{"user_id": userID},
+			bson.M{"$set": bson.M{"email_verified": true, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error verifying email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Email verified successfully"})
+	}
+}
+
+func ResendVerificationEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if user.EmailVerified {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified"})
+			return
+		}
+
+		if err := sendVerificationEmail(ctx, user); err != nil {
+			log.Printf("Error sending verification email: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error sending verification email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Verification email sent"})
+	}
+}
+
+// @Summary Forgot password
+// @Description Sends a password reset link to the email if such a user exists
+// @ID user-forgot-password
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "User email"
+// @Success 200 {object} map[string]string "Reset link sent"
+// @Failure 400 {object} ErrorResponse "Bad Request"
+// @Router /users/password/forgot [post]
+func ForgotPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request ForgotPasswordRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "valid email is required"})
+			return
+		}
+
+		// Ответ одинаковый для любых адресов, чтобы не раскрывать, кто зарегистрирован
+		response := gin.H{"msg": "If the email is registered, a reset link has been sent"}
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"email": request.Email}).Decode(&user)
+		if err != nil {
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		token, err := helper.GenerateActionToken(ctx, user.UserId, helper.ActionResetPassword, resetPasswordTokenTTL)
+		if err != nil {
+			log.Printf("Error generating reset token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error sending reset link"})
+			return
+		}
+
+		err = mailer.Default().Send(ctx, mailer.Message{
+			To:      user.Email,
+			Subject: "Reset your password",
+			Body:    fmt.Sprintf("Hi %s,\n\nto set a new password follow the link:\n%s/reset-password?token=%s\n\nThe link is valid for 1 hour. If you didn't request a reset, ignore this email.", user.FirstName, appURL(), token),
+		})
+		if err != nil {
+			log.Printf("Error sending reset email: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error sending reset link"})
+			return
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// @Summary Reset password
+// @Description Sets a new password using a single-use reset token and logs the user out everywhere
+// @ID user-reset-password
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string "Password updated"
+// @Failure 400 {object} ErrorResponse "Bad Request"
+// @Router /users/password/reset [post]
+func ResetPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request ResetPasswordRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token and password (min 8 characters) are required"})
+			return
+		}
+
+		userID, err := helper.ConsumeActionToken(ctx, request.Token, helper.ActionResetPassword)
+		if err != nil {
+			if errors.Is(err, helper.ErrActionTokenInvalid) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("Error resetting password: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error resetting password"})
+			return
+		}
+
+		// Ссылка пришла на почту, значит адрес тоже подтверждён
+		_, err = userCollection.UpdateOne(ctx,
+			bson.M{"user_id": userID},
+			bson.M{"$set": bson.M{"password": HashPassword(request.Password), "email_verified": true, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error resetting password"})
+			return
+		}
+
+		// Старые сессии могли принадлежать тому, кто узнал прежний пароль
+		if err := helper.RevokeAllTokens(ctx, userID); err != nil {
+			log.Printf("Error revoking tokens after password reset: %v", err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "Password updated successfully"})
+	}
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	helper "oiynlike/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+var resetLinkPattern = regexp.MustCompile(`reset-password\?token=(\S+)`)
+
+func postJSON(t *testing.T, handler gin.HandlerFunc, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/", handler)
+
+	data, _ := json.Marshal(body)
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// lastResetToken возвращает токен из последнего письма со ссылкой сброса пароля
+func lastResetToken(t *testing.T) string {
+	t.Helper()
+
+	data, err := os.ReadFile(testMailLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := resetLinkPattern.FindAllStringSubmatch(string(data), -1)
+	if len(matches) == 0 {
+		t.Fatal("no reset link in the mail log")
+	}
+	return matches[len(matches)-1][1]
+}
+
+func TestPasswordResetTokenIsSingleUse(t *testing.T) {
+	requireMongo(t)
+	user := insertTestUser(t, true)
+
+	recorder := postJSON(t, ForgotPassword(), ForgotPasswordRequest{Email: user.Email})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+	token := lastResetToken(t)
+
+	request := ResetPasswordRequest{Token: token, Password: "new-password"}
+	if recorder := postJSON(t, ResetPassword(), request); recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+	if recorder := postJSON(t, ResetPassword(), request); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected a used token to be rejected, got %d", recorder.Code)
+	}
+}
+
+func TestPasswordResetTokenIsReplacedByNewOne(t *testing.T) {
+	requireMongo(t)
+	user := insertTestUser(t, true)
+
+	postJSON(t, ForgotPassword(), ForgotPasswordRequest{Email: user.Email})
+	first := lastResetToken(t)
+	postJSON(t, ForgotPassword(), ForgotPasswordRequest{Email: user.Email})
+	second := lastResetToken(t)
+
+	if recorder := postJSON(t, ResetPassword(), ResetPasswordRequest{Token: first, Password: "new-password"}); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected a replaced token to be rejected, got %d", recorder.Code)
+	}
+	if recorder := postJSON(t, ResetPassword(), ResetPasswordRequest{Token: second, Password: "new-password"}); recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+}
+
+func TestExpiredActionTokenIsRejected(t *testing.T) {
+	requireMongo(t)
+	user := insertTestUser(t, true)
+
+	token, err := helper.GenerateActionToken(context.Background(), user.UserId, helper.ActionResetPassword, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recorder := postJSON(t, ResetPassword(), ResetPasswordRequest{Token: token, Password: "new-password"}); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected an expired token to be rejected, got %d", recorder.Code)
+	}
+}
+
+func TestActionTokenIsBoundToAction(t *testing.T) {
+	requireMongo(t)
+	user := insertTestUser(t, false)
+
+	token, err := helper.GenerateActionToken(context.Background(), user.UserId, helper.ActionVerifyEmail, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recorder := postJSON(t, ResetPassword(), ResetPasswordRequest{Token: token, Password: "new-password"}); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected a verification token to be rejected for a reset, got %d", recorder.Code)
+	}
+	if recorder := postJSON(t, VerifyEmail(), TokenRequest{Token: token}); recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+}
@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	helper "oiynlike/helpers"
+	"oiynlike/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrHostCannotLeave = errors.New("host user cannot leave own gameCard")
+	ErrNotParticipant  = errors.New("user is neither a player nor on the waitlist")
+	// ErrGameCardClosed — игра завершена, отменена или не прошла модерацию, её состав больше не меняется
+	ErrGameCardClosed = errors.New("gameCard is closed, its players cannot change")
+)
+
+// AddPlayerToWaitlist ставит пользователя в конец листа ожидания заполненной карточки
+// одной условной атомарной операцией. Возвращает позицию в очереди, начиная с 1.
+func AddPlayerToWaitlist(c *gin.Context, userID string, gameCardID primitive.ObjectID) (int, error) {
+	user, err := GetUserByID(c, userID)
+	if err != nil {
+		return 0, fmt.Errorf("error retrieving user data: %v", err)
+	}
+
+	filter := bson.M{
+		"_id":                     gameCardID,
+		"status":                  bson.M{"$in": bson.A{models.GameCardStatusActive, models.GameCardStatusFull}},
+		"host_user.user_id":       bson.M{"$ne": userID},
+		"matched_players.user_id": bson.M{"$ne": userID},
+		"waitlist.user_id":        bson.M{"$ne": userID},
+		"$expr": bson.M{"$gte": bson.A{
+			bson.M{"$size": bson.M{"$ifNull": bson.A{"$matched_players", bson.A{}}}},
+			"$max_players",
+		}},
+	}
+	update := bson.M{
+		"$push": bson.M{"waitlist": matchedPlayerFromUser(user)},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+
+	var updatedCard models.GameCard
+	err = gameCardCollection.FindOneAndUpdate(c, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedCard)
+	if err == mongo.ErrNoDocuments {
+		return 0, joinRejectionReason(c, userID, gameCardID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error updating gameCard waitlist: %v", err)
+	}
+
+	return len(updatedCard.Waitlist), nil
+}
+
+// removeParticipant убирает пользователя из игроков или из листа ожидания карточки,
+// открытой для присоединения: состав закрытых игр остаётся в истории как был.
+// Возвращает карточку после изменения и признак того, что пользователь был игроком.
+func removeParticipant(ctx context.Context, userID string, gameCardID primitive.ObjectID) (models.GameCard, bool, error) {
+	var updatedCard models.GameCard
+	openStatuses := bson.A{models.GameCardStatusActive, models.GameCardStatusFull}
+	after := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	// Сначала пробуем выйти из игроков
+	err := gameCardCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": gameCardID, "status": bson.M{"$in": openStatuses}, "matched_players.user_id": userID},
+		bson.M{
+			"$pull": bson.M{"matched_players": bson.M{"user_id": userID}},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+		after,
+	).Decode(&updatedCard)
+	if err == nil {
+		return updatedCard, true, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return updatedCard, false, fmt.Errorf("error updating gameCard: %v", err)
+	}
+
+	// Затем — из листа ожидания
+	err = gameCardCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": gameCardID, "status": bson.M{"$in": openStatuses}, "waitlist.user_id": userID},
+		bson.M{
+			"$pull": bson.M{"waitlist": bson.M{"user_id": userID}},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+		after,
+	).Decode(&updatedCard)
+	if err == nil {
+		return updatedCard, false, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return updatedCard, false, fmt.Errorf("error updating gameCard waitlist: %v", err)
+	}
+
+	gameCard, err := getGameCardByID(ctx, gameCardID)
+	if err == mongo.ErrNoDocuments {
+		return updatedCard, false, ErrGameCardNotFound
+	}
+	if err != nil {
+		return updatedCard, false, fmt.Errorf("error retrieving gameCard data: %v", err)
+	}
+	if gameCard.Status != models.GameCardStatusActive && gameCard.Status != models.GameCardStatusFull {
+		return updatedCard, false, ErrGameCardClosed
+	}
+	if gameCard.HostUser.UserID == userID {
+		return updatedCard, false, ErrHostCannotLeave
+	}
+	return updatedCard, false, ErrNotParticipant
+}
+
+// promoteFromWaitlist переводит первых игроков из листа ожидания в matched_players,
+// пока есть свободные места. Каждое перемещение — условная атомарная операция:
+// первый в очереди должен совпадать с прочитанным, а мест должно хватать.
+func promoteFromWaitlist(ctx context.Context, gameCard *models.GameCard) ([]models.MatchedPlayer, error) {
+	var promoted []models.MatchedPlayer
+
+	for len(gameCard.Waitlist) > 0 && len(gameCard.MatchedPlayers) < gameCard.MaxPlayers {
+		candidate := gameCard.Waitlist[0]
+
+		filter := bson.M{
+			"_id":                gameCard.ID,
+			"status":             bson.M{"$in": bson.A{models.GameCardStatusActive, models.GameCardStatusFull}},
+			"waitlist.0.user_id": candidate.UserID,
+			"$expr": bson.M{"$lt": bson.A{
+				bson.M{"$size": bson.M{"$ifNull": bson.A{"$matched_players", bson.A{}}}},
+				"$max_players",
+			}},
+		}
+		update := bson.M{
+			"$pop":  bson.M{"waitlist": -1},
+			"$push": bson.M{"matched_players": candidate},
+			"$set":  bson.M{"updated_at": time.Now()},
+		}
+
+		var updatedCard models.GameCard
+		err := gameCardCollection.FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updatedCard)
+		if err == mongo.ErrNoDocuments {
+			// Карточку изменили параллельно — перечитываем и пробуем снова
+			updatedCard, err = getGameCardByID(ctx, gameCard.ID)
+			if err != nil {
+				return promoted, fmt.Errorf("error retrieving gameCard data: %v", err)
+			}
+			if updatedCard.Status != models.GameCardStatusActive && updatedCard.Status != models.GameCardStatusFull {
+				*gameCard = updatedCard
+				return promoted, nil
+			}
+			*gameCard = updatedCard
+			continue
+		}
+		if err != nil {
+			return promoted, fmt.Errorf("error promoting waitlisted player: %v", err)
+		}
+
+		*gameCard = updatedCard
+		promoted = append(promoted, candidate)
+	}
+
+	return promoted, nil
+}
+
+// reopenIfNeeded возвращает заполненную ранее карточку в "active", если появились места
+func reopenIfNeeded(ctx context.Context, gameCard *models.GameCard) error {
+	if gameCard.Status != models.GameCardStatusFull || len(gameCard.MatchedPlayers) >= gameCard.MaxPlayers {
+		return nil
+	}
+
+	condition := bson.M{
+		"$expr": bson.M{"$lt": bson.A{
+			bson.M{"$size": bson.M{"$ifNull": bson.A{"$matched_players", bson.A{}}}},
+			"$max_players",
+		}},
+	}
+
+	err := transitionGameCardStatus(ctx, gameCard, models.GameCardStatusActive, helper.StatusActorSystem, "", "", condition)
+	if err != nil && !errors.Is(err, ErrGameCardStatusChanged) {
+		return err
+	}
+	return nil
+}
+
+func LeaveGameCard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Извлекаем user_id из JWT
+		userID, _ := c.Get("uid")
+		userIDString := fmt.Sprintf("%v", userID)
+
+		var leaveRequest JoinRequest
+		if err := c.ShouldBindJSON(&leaveRequest); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		gameCard, wasPlayer, err := removeParticipant(c, userIDString, leaveRequest.GameCardID)
+		if errors.Is(err, ErrGameCardNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, ErrGameCardClosed) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, ErrHostCannotLeave) || errors.Is(err, ErrNotParticipant) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !wasPlayer {
+			c.JSON(http.StatusOK, gin.H{"msg": "User left the waitlist successfully"})
+			return
+		}
+
+		if err := removeChatMember(c, gameCard.ID, userIDString); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Освободившееся место занимает первый игрок из листа ожидания
+		if err := syncRoster(c, &gameCard); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"msg": "User left the gameCard successfully"})
+	}
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"oiynlike/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRemoveParticipantKeepsClosedRoster(t *testing.T) {
+	requireMongo(t)
+
+	ctx := context.Background()
+	gameCard := insertTestGameCard(t, 1)
+	player := insertTestUser(t, true)
+	waiting := insertTestUser(t, true)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if _, err := AddPlayerToGameCard(c, player.UserId, gameCard.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddPlayerToWaitlist(c, waiting.UserId, gameCard.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := gameCardCollection.UpdateOne(ctx,
+		bson.M{"_id": gameCard.ID},
+		bson.M{"$set": bson.M{"status": models.GameCardStatusCompleted}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, user := range []models.User{player, waiting} {
+		if _, _, err := removeParticipant(ctx, user.UserId, gameCard.ID); !errors.Is(err, ErrGameCardClosed) {
+			t.Fatalf("expected ErrGameCardClosed, got %v", err)
+		}
+	}
+
+	stored, err := getGameCardByID(ctx, gameCard.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.MatchedPlayers) != 1 || len(stored.Waitlist) != 1 {
+		t.Fatalf("closed roster changed: %d players, %d waiting", len(stored.MatchedPlayers), len(stored.Waitlist))
+	}
+}
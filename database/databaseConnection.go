@@ -2,7 +2,9 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"sync"
 
@@ -19,12 +21,19 @@ var (
 
 // InitializeMongoDB создает и возвращает новый клиент MongoDB
 func InitializeMongoDB() (*mongo.Client, error) {
+	// Без файла .env настройки берутся из окружения (например, в тестах)
 	err := godotenv.Load()
-	if err != nil {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return nil, fmt.Errorf("Error loading .env file: %v", err)
 	}
 
 	mongoURI := os.Getenv("MONGODB_URI")
+	if mongoURI == "" {
+		mongoURI = os.Getenv("MONGODB_URL")
+	}
+	if mongoURI == "" {
+		return nil, fmt.Errorf("MONGODB_URI is not set")
+	}
 
 	clientOptions := options.Client().ApplyURI(mongoURI)
 
@@ -65,6 +74,15 @@ func ConnectToMongoDB() (*mongo.Client, error) {
 	return mongoClient, nil
 }
 
+// databaseName возвращает имя базы данных: MONGODB_DATABASE или "oiynlike".
+// Тесты указывают отдельную базу, чтобы не трогать рабочие данные.
+func databaseName() string {
+	if name := os.Getenv("MONGODB_DATABASE"); name != "" {
+		return name
+	}
+	return "oiynlike"
+}
+
 // OpenCollection открывает коллекцию MongoDB
 func OpenCollection(collectionName string) *mongo.Collection {
 	client, err := ConnectToMongoDB()
@@ -73,6 +91,6 @@ func OpenCollection(collectionName string) *mongo.Collection {
 		return nil
 	}
 
-	var collection *mongo.Collection = client.Database("oiynlike").Collection(collectionName)
+	var collection *mongo.Collection = client.Database(databaseName()).Collection(collectionName)
 	return collection
 }
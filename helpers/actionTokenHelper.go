@@ -0,0 +1,109 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"oiynlike/database"
+	"oiynlike/models"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var actionTokenCollection *mongo.Collection = database.OpenCollection("action_tokens")
+
+// Назначение одноразовых токенов
+const (
+	ActionVerifyEmail   = "verify_email"
+	ActionResetPassword = "reset_password"
+)
+
+var ErrActionTokenInvalid = errors.New("token is invalid, expired or has already been used")
+
+// GenerateActionToken выдаёт подписанный одноразовый токен для действия action
+func GenerateActionToken(ctx context.Context, userId string, action string, ttl time.Duration) (string, error) {
+	tokenID := primitive.NewObjectID().Hex()
+	expiresAt := time.Now().Add(ttl)
+
+	tokenString, err := signToken(jwt.MapClaims{
+		"uid":  userId,
+		"jti":  tokenID,
+		"type": action,
+		"exp":  expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Предыдущие неиспользованные токены того же назначения больше не действуют
+	_, err = actionTokenCollection.UpdateMany(ctx,
+		bson.M{"user_id": userId, "action": action, "used": false},
+		bson.M{"$set": bson.M{"used": true}},
+	)
+	if err != nil {
+		return "", fmt.Errorf("error invalidating previous tokens: %v", err)
+	}
+
+	record := models.ActionToken{
+		ID:        primitive.NewObjectID(),
+		TokenID:   tokenID,
+		UserID:    userId,
+		Action:    action,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	_, err = actionTokenCollection.InsertOne(ctx, record)
+	if err != nil {
+		return "", fmt.Errorf("error storing token: %v", err)
+	}
+
+	return tokenString, nil
+}
+
+// ConsumeActionToken проверяет токен действия action, помечает его использованным
+// и возвращает id пользователя, которому он был выдан
+func ConsumeActionToken(ctx context.Context, tokenString string, action string) (string, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return "", ErrActionTokenInvalid
+	}
+
+	if claims["type"] != action {
+		return "", ErrActionTokenInvalid
+	}
+
+	tokenID, _ := claims["jti"].(string)
+	uid, _ := claims["uid"].(string)
+
+	// Атомарная отметка гарантирует, что токен сработает только один раз
+	var record models.ActionToken
+	err = actionTokenCollection.FindOneAndUpdate(ctx,
+		bson.M{"jti": tokenID, "user_id": uid, "action": action, "used": false},
+		bson.M{"$set": bson.M{"used": true}},
+	).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return "", ErrActionTokenInvalid
+	}
+	if err != nil {
+		return "", fmt.Errorf("error consuming token: %v", err)
+	}
+
+	return uid, nil
+}
+
+// IsEmailVerified проверяет, подтвердил ли пользователь почту.
+// Аккаунты, созданные до появления подтверждения, считаются подтверждёнными.
+func IsEmailVerified(ctx context.Context, userId string) (bool, error) {
+	count, err := userCollection.CountDocuments(ctx, bson.M{"user_id": userId, "email_verified": false})
+	if err != nil {
+		return false, fmt.Errorf("error checking email verification: %v", err)
+	}
+	return count == 0, nil
+}
@@ -16,17 +16,28 @@ func CheckUserType(c *gin.Context, role string) (err error) {
 	return err
 }
 
-func MatchUserTypeToUid(c *gin.Context, userId string) (err error) {
+// HasRolePermission проверяет, что роль пользователя запроса обладает правом permission.
+// Права ролей с обязательной 2FA действуют, только если вход подтверждён вторым фактором.
+func HasRolePermission(c *gin.Context, permission Permission) bool {
 	userType := c.GetString("user_type")
+	if !HasPermission(userType, permission) {
+		return false
+	}
+	return !RoleRequiresMFA(userType) || c.GetBool("mfa")
+}
+
+// MatchUserTypeToUid разрешает доступ к ресурсу, принадлежащему userId,
+// только его владельцу или роли, которой разрешено изменять чужие ресурсы
+func MatchUserTypeToUid(c *gin.Context, userId string) (err error) {
 	uid := c.GetString("uid")
-	err = nil
 
-	if userType == "USER" && uid != userId {
-		err = errors.New("Unauthorized to acces this resource")
-		return err
+	if uid != "" && uid == userId {
+		return nil
 	}
 
-	err = CheckUserType(c, userType)
-	return err
+	if HasRolePermission(c, PermManageAnyResource) {
+		return nil
+	}
 
+	return errors.New("Unauthorized to access this resource")
 }
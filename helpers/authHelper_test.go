@@ -0,0 +1,38 @@
+package helpers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testAuthContext(uid string, userType string, mfa bool) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("uid", uid)
+	c.Set("user_type", userType)
+	c.Set("mfa", mfa)
+	return c
+}
+
+func TestMatchUserTypeToUid(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       *gin.Context
+		allowed bool
+	}{
+		{"owner", testAuthContext("owner", RoleUser, false), true},
+		{"other user", testAuthContext("other", RoleUser, false), false},
+		{"admin with second factor", testAuthContext("admin", RoleAdmin, true), true},
+		{"admin without second factor", testAuthContext("admin", RoleAdmin, false), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := MatchUserTypeToUid(tt.c, "owner")
+			if (err == nil) != tt.allowed {
+				t.Fatalf("expected allowed=%v, got %v", tt.allowed, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+
+	"oiynlike/models"
+)
+
+// Кто выполняет переход статуса карточки
+const (
+	StatusActorHost      = "host"
+	StatusActorModerator = "moderator"
+	// StatusActorSystem — автоматические переходы (набор заполнен, освободилось место)
+	StatusActorSystem = "system"
+)
+
+// ErrStatusTransitionForbidden возвращается, когда переход допустим, но не для этого участника
+var ErrStatusTransitionForbidden = errors.New("status transition is not allowed for this actor")
+
+// InvalidTransitionError возвращается для перехода, которого нет в жизненном цикле карточки
+type InvalidTransitionError struct {
+	From string
+	To   string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("invalid gameCard status transition from %q to %q", e.From, e.To)
+}
+
+// gameCardTransitions — для каждого статуса: в какие статусы из него можно перейти и кто это может сделать
+var gameCardTransitions = map[string]map[string][]string{
+	models.GameCardStatusDraft: {
+		models.GameCardStatusModeration: {StatusActorHost},
+		models.GameCardStatusCancelled:  {StatusActorHost},
+	},
+	models.GameCardStatusModeration: {
+		models.GameCardStatusActive:    {StatusActorModerator},
+		models.GameCardStatusRejected:  {StatusActorModerator},
+		models.GameCardStatusDraft:     {StatusActorHost},
+		models.GameCardStatusCancelled: {StatusActorHost, StatusActorSystem},
+	},
+	models.GameCardStatusActive: {
+		models.GameCardStatusFull:      {StatusActorSystem},
+		models.GameCardStatusStarted:   {StatusActorHost, StatusActorSystem},
+		models.GameCardStatusCancelled: {StatusActorHost, StatusActorModerator},
+	},
+	models.GameCardStatusFull: {
+		models.GameCardStatusActive:    {StatusActorSystem},
+		models.GameCardStatusStarted:   {StatusActorHost, StatusActorSystem},
+		models.GameCardStatusCancelled: {StatusActorHost, StatusActorModerator},
+	},
+	models.GameCardStatusStarted: {
+		models.GameCardStatusCompleted: {StatusActorHost, StatusActorSystem},
+		models.GameCardStatusCancelled: {StatusActorHost, StatusActorModerator},
+	},
+	models.GameCardStatusRejected: {
+		models.GameCardStatusDraft: {StatusActorHost},
+	},
+}
+
+// IsValidGameCardStatus проверяет, что статус известен жизненному циклу карточки
+func IsValidGameCardStatus(status string) bool {
+	switch status {
+	case models.GameCardStatusCompleted, models.GameCardStatusCancelled:
+		return true
+	}
+	_, ok := gameCardTransitions[status]
+	return ok
+}
+
+// CheckGameCardTransition проверяет, что переход from → to существует и разрешён участнику actor.
+// Возвращает *InvalidTransitionError или ErrStatusTransitionForbidden.
+func CheckGameCardTransition(from, to, actor string) error {
+	actors, ok := gameCardTransitions[from][to]
+	if !ok {
+		return &InvalidTransitionError{From: from, To: to}
+	}
+	if !containsString(actors, actor) {
+		return ErrStatusTransitionForbidden
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"oiynlike/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// EarthRadiusKm — средний радиус Земли, по нему считаются расстояния и радиус поиска
+const EarthRadiusKm = 6371.0
+
+// ValidateCoordinates проверяет, что широта и долгота лежат в допустимых пределах
+func ValidateCoordinates(lat, lng float64) error {
+	if math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return fmt.Errorf("latitude must be between -90 and 90")
+	}
+	if math.IsNaN(lng) || lng < -180 || lng > 180 {
+		return fmt.Errorf("longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+// ParseCoordinates разбирает широту и долготу, записанные строками
+func ParseCoordinates(lat, lng string) (*models.GeoPoint, error) {
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latitude %q", lat)
+	}
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(lng), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid longitude %q", lng)
+	}
+	if err := ValidateCoordinates(latitude, longitude); err != nil {
+		return nil, err
+	}
+	return models.NewGeoPoint(latitude, longitude), nil
+}
+
+// ParseLatLng разбирает точку вида "lat,lng" (параметр near)
+func ParseLatLng(value string) (*models.GeoPoint, error) {
+	lat, lng, ok := strings.Cut(value, ",")
+	if !ok {
+		return nil, fmt.Errorf("point must be in lat,lng format")
+	}
+	return ParseCoordinates(lat, lng)
+}
+
+// ValidateGeoPoint проверяет точку GeoJSON, переданную клиентом
+func ValidateGeoPoint(point *models.GeoPoint) error {
+	if point.Type != "Point" {
+		return fmt.Errorf("location type must be Point")
+	}
+	if len(point.Coordinates) != 2 {
+		return fmt.Errorf("location coordinates must be [longitude, latitude]")
+	}
+	return ValidateCoordinates(point.Coordinates[1], point.Coordinates[0])
+}
+
+// WithinRadiusFilter отбирает документы, точка field которых не дальше radiusKm от center.
+// $geoWithin, в отличие от $near, можно использовать в CountDocuments и вместе с сортировкой.
+func WithinRadiusFilter(center *models.GeoPoint, radiusKm float64) bson.M {
+	return bson.M{"$geoWithin": bson.M{
+		"$centerSphere": bson.A{center.Coordinates, radiusKm / EarthRadiusKm},
+	}}
+}
+
+// DistanceKmExpr — выражение агрегации, вычисляющее расстояние в километрах от center
+// до точки field по формуле гаверсинусов
+func DistanceKmExpr(center *models.GeoPoint, field string) bson.M {
+	lat1 := center.Coordinates[1] * math.Pi / 180
+	lng1 := center.Coordinates[0] * math.Pi / 180
+
+	lat2 := bson.M{"$degreesToRadians": bson.M{"$arrayElemAt": bson.A{"$" + field + ".coordinates", 1}}}
+	lng2 := bson.M{"$degreesToRadians": bson.M{"$arrayElemAt": bson.A{"$" + field + ".coordinates", 0}}}
+
+	halfSinSquared := func(delta interface{}) bson.M {
+		return bson.M{"$pow": bson.A{bson.M{"$sin": bson.M{"$divide": bson.A{delta, 2}}}, 2}}
+	}
+	a := bson.M{"$add": bson.A{
+		halfSinSquared(bson.M{"$subtract": bson.A{lat2, lat1}}),
+		bson.M{"$multiply": bson.A{
+			math.Cos(lat1),
+			bson.M{"$cos": lat2},
+			halfSinSquared(bson.M{"$subtract": bson.A{lng2, lng1}}),
+		}},
+	}}
+
+	return bson.M{"$multiply": bson.A{
+		2 * EarthRadiusKm,
+		bson.M{"$asin": bson.M{"$min": bson.A{1, bson.M{"$sqrt": a}}}},
+	}}
+}
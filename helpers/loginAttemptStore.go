@@ -0,0 +1,136 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"oiynlike/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginAttemptStore хранит состояние неудачных попыток входа по ключу
+// (аккаунт или IP-адрес)
+type LoginAttemptStore interface {
+	// Get возвращает состояние ключа; для неизвестного ключа — пустое состояние
+	Get(ctx context.Context, key string) (models.LoginAttempt, error)
+	// RecordFailure атомарно увеличивает счётчик неудач и возвращает новое состояние
+	RecordFailure(ctx context.Context, key string, at time.Time) (models.LoginAttempt, error)
+	// Block запрещает попытки до nextAttemptAt и блокирует ключ до lockedUntil
+	Block(ctx context.Context, key string, nextAttemptAt time.Time, lockedUntil time.Time) error
+	// Reset сбрасывает состояние ключа
+	Reset(ctx context.Context, key string) error
+}
+
+// MongoLoginAttemptStore хранит попытки входа в коллекции MongoDB
+type MongoLoginAttemptStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoLoginAttemptStore(collection *mongo.Collection) *MongoLoginAttemptStore {
+	return &MongoLoginAttemptStore{collection: collection}
+}
+
+func (s *MongoLoginAttemptStore) Get(ctx context.Context, key string) (models.LoginAttempt, error) {
+	attempt := models.LoginAttempt{Key: key}
+	err := s.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&attempt)
+	if err == mongo.ErrNoDocuments {
+		return attempt, nil
+	}
+	if err != nil {
+		return attempt, fmt.Errorf("error retrieving login attempts: %v", err)
+	}
+	return attempt, nil
+}
+
+func (s *MongoLoginAttemptStore) RecordFailure(ctx context.Context, key string, at time.Time) (models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := s.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		bson.M{"$inc": bson.M{"failures": 1}, "$set": bson.M{"last_failure_at": at}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempt)
+	if err != nil {
+		return attempt, fmt.Errorf("error recording login failure: %v", err)
+	}
+	return attempt, nil
+}
+
+func (s *MongoLoginAttemptStore) Block(ctx context.Context, key string, nextAttemptAt time.Time, lockedUntil time.Time) error {
+	_, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": key},
+		bson.M{"$set": bson.M{"next_attempt_at": nextAttemptAt, "locked_until": lockedUntil}},
+	)
+	if err != nil {
+		return fmt.Errorf("error blocking login attempts: %v", err)
+	}
+	return nil
+}
+
+func (s *MongoLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": key})
+	if err != nil {
+		return fmt.Errorf("error resetting login attempts: %v", err)
+	}
+	return nil
+}
+
+// MemoryLoginAttemptStore хранит попытки входа в памяти процесса.
+// Подходит для тестов и запуска в одном экземпляре.
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempt
+}
+
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{attempts: make(map[string]models.LoginAttempt)}
+}
+
+func (s *MemoryLoginAttemptStore) Get(ctx context.Context, key string) (models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok {
+		attempt = models.LoginAttempt{Key: key}
+	}
+	return attempt, nil
+}
+
+func (s *MemoryLoginAttemptStore) RecordFailure(ctx context.Context, key string, at time.Time) (models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt := s.attempts[key]
+	attempt.Key = key
+	attempt.Failures++
+	attempt.LastFailureAt = at
+	s.attempts[key] = attempt
+	return attempt, nil
+}
+
+func (s *MemoryLoginAttemptStore) Block(ctx context.Context, key string, nextAttemptAt time.Time, lockedUntil time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok {
+		return nil
+	}
+	attempt.NextAttemptAt = nextAttemptAt
+	attempt.LockedUntil = lockedUntil
+	s.attempts[key] = attempt
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
@@ -0,0 +1,147 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"oiynlike/models"
+)
+
+// LoginBlockedError возвращается, когда попытки входа временно запрещены
+type LoginBlockedError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginBlockedError) Error() string {
+	if e.Locked {
+		return "Too many failed login attempts, account is temporarily locked"
+	}
+	return "Too many failed login attempts, try again later"
+}
+
+// LoginGuard ограничивает подбор пароля: после каждой неудачи следующая попытка
+// откладывается всё дольше, а после MaxFailures неудач ключ блокируется на LockoutDuration.
+// Неудачи считаются отдельно для аккаунта и для IP-адреса.
+type LoginGuard struct {
+	Store LoginAttemptStore
+
+	MaxAccountFailures int
+	MaxIPFailures      int
+	LockoutDuration    time.Duration
+	BaseDelay          time.Duration
+	MaxDelay           time.Duration
+	// FailureWindow — через сколько после последней неудачи счётчик забывается
+	FailureWindow time.Duration
+
+	now func() time.Time
+}
+
+// NewLoginGuard создаёт LoginGuard с настройками по умолчанию
+func NewLoginGuard(store LoginAttemptStore) *LoginGuard {
+	return &LoginGuard{
+		Store:              store,
+		MaxAccountFailures: 5,
+		MaxIPFailures:      20,
+		LockoutDuration:    15 * time.Minute,
+		BaseDelay:          time.Second,
+		MaxDelay:           time.Minute,
+		FailureWindow:      time.Hour,
+		now:                time.Now,
+	}
+}
+
+func (g *LoginGuard) currentTime() time.Time {
+	if g.now != nil {
+		return g.now()
+	}
+	return time.Now()
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check проверяет, можно ли сейчас пытаться войти в аккаунт email с адреса ip
+func (g *LoginGuard) Check(ctx context.Context, email string, ip string) error {
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		attempt, err := g.Store.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		now := g.currentTime()
+
+		if now.Before(attempt.LockedUntil) {
+			return &LoginBlockedError{RetryAfter: attempt.LockedUntil.Sub(now), Locked: true}
+		}
+		if now.Before(attempt.NextAttemptAt) {
+			return &LoginBlockedError{RetryAfter: attempt.NextAttemptAt.Sub(now)}
+		}
+
+		// Давние неудачи больше не учитываются
+		if attempt.Failures > 0 && now.Sub(attempt.LastFailureAt) > g.FailureWindow {
+			if err := g.Store.Reset(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RecordFailure учитывает неудачную попытку входа
+func (g *LoginGuard) RecordFailure(ctx context.Context, email string, ip string) error {
+	if err := g.recordFailure(ctx, accountKey(email), g.MaxAccountFailures); err != nil {
+		return err
+	}
+	return g.recordFailure(ctx, ipKey(ip), g.MaxIPFailures)
+}
+
+func (g *LoginGuard) recordFailure(ctx context.Context, key string, maxFailures int) error {
+	now := g.currentTime()
+
+	attempt, err := g.Store.RecordFailure(ctx, key, now)
+	if err != nil {
+		return err
+	}
+
+	nextAttemptAt := now.Add(g.delay(attempt))
+	var lockedUntil time.Time
+	if attempt.Failures >= maxFailures {
+		lockedUntil = now.Add(g.LockoutDuration)
+	}
+
+	return g.Store.Block(ctx, key, nextAttemptAt, lockedUntil)
+}
+
+// delay растёт вдвое с каждой неудачей: 1s, 2s, 4s ... но не больше MaxDelay
+func (g *LoginGuard) delay(attempt models.LoginAttempt) time.Duration {
+	delay := g.BaseDelay
+	for i := 1; i < attempt.Failures && delay < g.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > g.MaxDelay {
+		delay = g.MaxDelay
+	}
+	return delay
+}
+
+// RecordSuccess сбрасывает счётчик аккаунта после успешного входа.
+// Счётчик IP не сбрасывается, иначе вход в свой аккаунт обнулял бы подбор чужих.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, email string) error {
+	return g.Store.Reset(ctx, accountKey(email))
+}
+
+// Unlock снимает блокировку с аккаунта
+func (g *LoginGuard) Unlock(ctx context.Context, email string) error {
+	if email == "" {
+		return fmt.Errorf("email is required")
+	}
+	return g.Store.Reset(ctx, accountKey(email))
+}
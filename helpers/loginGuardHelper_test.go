@@ -0,0 +1,190 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"oiynlike/models"
+)
+
+// testClock — часы LoginGuard, которые тест переводит вручную
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestLoginGuard() (*LoginGuard, *testClock) {
+	clock := &testClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	guard := NewLoginGuard(NewMemoryLoginAttemptStore())
+	guard.now = clock.Now
+	return guard, clock
+}
+
+func blockedError(t *testing.T, err error) *LoginBlockedError {
+	t.Helper()
+
+	var blocked *LoginBlockedError
+	if !errors.As(err, &blocked) {
+		t.Fatalf("expected LoginBlockedError, got %v", err)
+	}
+	return blocked
+}
+
+// failAfterDelay записывает неудачу, дождавшись окончания задержки после предыдущей
+func failAfterDelay(t *testing.T, guard *LoginGuard, clock *testClock, email string, ip string) {
+	t.Helper()
+
+	ctx := context.Background()
+	for {
+		err := guard.Check(ctx, email, ip)
+		if err == nil {
+			break
+		}
+		blocked := blockedError(t, err)
+		if blocked.Locked {
+			t.Fatalf("unexpected lockout of %s from %s", email, ip)
+		}
+		clock.Advance(blocked.RetryAfter)
+	}
+	if err := guard.RecordFailure(ctx, email, ip); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoginGuardProgressiveDelay(t *testing.T) {
+	guard, clock := newTestLoginGuard()
+	ctx := context.Background()
+
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		failAfterDelay(t, guard, clock, "player@example.com", "10.0.0.1")
+
+		blocked := blockedError(t, guard.Check(ctx, "player@example.com", "10.0.0.1"))
+		if blocked.Locked || blocked.RetryAfter != expected {
+			t.Fatalf("failure %d: expected delay %v, got %+v", i+1, expected, blocked)
+		}
+	}
+
+	clock.Advance(4 * time.Second)
+	if err := guard.Check(ctx, "player@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("expected a retry after the delay, got %v", err)
+	}
+}
+
+func TestLoginGuardDelayIsCapped(t *testing.T) {
+	guard, _ := newTestLoginGuard()
+
+	for failures := 1; failures <= 12; failures++ {
+		if delay := guard.delay(models.LoginAttempt{Failures: failures}); delay > guard.MaxDelay {
+			t.Fatalf("delay %v after %d failures exceeds %v", delay, failures, guard.MaxDelay)
+		}
+	}
+}
+
+func TestLoginGuardAccountLockout(t *testing.T) {
+	guard, clock := newTestLoginGuard()
+	ctx := context.Background()
+
+	// Подбор пароля к одному аккаунту с разных адресов блокирует аккаунт
+	for i := 0; i < guard.MaxAccountFailures; i++ {
+		failAfterDelay(t, guard, clock, "player@example.com", fmt.Sprintf("10.0.0.%d", i))
+	}
+
+	blocked := blockedError(t, guard.Check(ctx, "player@example.com", "10.0.1.1"))
+	if !blocked.Locked || blocked.RetryAfter != guard.LockoutDuration {
+		t.Fatalf("expected the account to be locked for %v, got %+v", guard.LockoutDuration, blocked)
+	}
+
+	// Другие аккаунты с тех же адресов не блокируются
+	if err := guard.Check(ctx, "other@example.com", "10.0.0.0"); err != nil {
+		t.Fatalf("other account is blocked: %v", err)
+	}
+
+	clock.Advance(guard.LockoutDuration)
+	if err := guard.Check(ctx, "player@example.com", "10.0.1.1"); err != nil {
+		t.Fatalf("expected the lockout to expire, got %v", err)
+	}
+}
+
+func TestLoginGuardIPLockout(t *testing.T) {
+	guard, clock := newTestLoginGuard()
+	ctx := context.Background()
+
+	// Перебор разных аккаунтов с одного адреса блокирует адрес
+	for i := 0; i < guard.MaxIPFailures; i++ {
+		failAfterDelay(t, guard, clock, fmt.Sprintf("player%d@example.com", i), "10.0.0.1")
+	}
+
+	blocked := blockedError(t, guard.Check(ctx, "new@example.com", "10.0.0.1"))
+	if !blocked.Locked {
+		t.Fatalf("expected the address to be locked, got %+v", blocked)
+	}
+
+	if err := guard.Check(ctx, "new@example.com", "10.0.0.2"); err != nil {
+		t.Fatalf("other address is blocked: %v", err)
+	}
+}
+
+func TestLoginGuardUnlockAndSuccess(t *testing.T) {
+	guard, clock := newTestLoginGuard()
+	ctx := context.Background()
+
+	for i := 0; i < guard.MaxAccountFailures; i++ {
+		failAfterDelay(t, guard, clock, "player@example.com", fmt.Sprintf("10.0.0.%d", i))
+	}
+	if err := guard.Check(ctx, "player@example.com", "10.0.1.1"); err == nil {
+		t.Fatal("expected the account to be locked")
+	}
+
+	if err := guard.Unlock(ctx, "player@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.Check(ctx, "player@example.com", "10.0.1.1"); err != nil {
+		t.Fatalf("expected the account to be unlocked, got %v", err)
+	}
+	if err := guard.Unlock(ctx, ""); err == nil {
+		t.Fatal("expected an error for an empty email")
+	}
+
+	// Успешный вход обнуляет счётчик аккаунта
+	failAfterDelay(t, guard, clock, "player@example.com", "10.0.1.1")
+	if err := guard.RecordSuccess(ctx, "player@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	attempt, err := guard.Store.Get(ctx, accountKey("player@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Failures != 0 {
+		t.Fatalf("expected the account counter to be reset, got %d failures", attempt.Failures)
+	}
+}
+
+func TestLoginGuardForgetsOldFailures(t *testing.T) {
+	guard, clock := newTestLoginGuard()
+	ctx := context.Background()
+
+	for i := 0; i < guard.MaxAccountFailures-1; i++ {
+		failAfterDelay(t, guard, clock, "player@example.com", "10.0.0.1")
+	}
+
+	clock.Advance(guard.FailureWindow + time.Minute)
+	failAfterDelay(t, guard, clock, "player@example.com", "10.0.0.1")
+
+	attempt, err := guard.Store.Get(ctx, accountKey("player@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Failures != 1 {
+		t.Fatalf("expected old failures to be forgotten, got %d failures", attempt.Failures)
+	}
+}
@@ -0,0 +1,55 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mfaPendingTokenTTL — сколько действует токен между вводом пароля и кода
+const mfaPendingTokenTTL = 5 * time.Minute
+
+// GenerateMFAPendingToken выдаёт короткоживущий токен «ожидается второй фактор».
+// Вместо токена доступа его получает пользователь с включённой 2FA после ввода пароля.
+func GenerateMFAPendingToken(uid string) (string, error) {
+	return signToken(jwt.MapClaims{
+		"uid":  uid,
+		"jti":  primitive.NewObjectID().Hex(),
+		"type": "mfa_pending",
+		"exp":  time.Now().Add(mfaPendingTokenTTL).Unix(),
+	})
+}
+
+// ValidateMFAPendingToken проверяет токен «ожидается второй фактор» и возвращает id пользователя
+func ValidateMFAPendingToken(tokenString string) (string, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return "", err
+	}
+
+	if claims["type"] != "mfa_pending" {
+		return "", fmt.Errorf("Invalid token type")
+	}
+
+	uid, _ := claims["uid"].(string)
+	if uid == "" {
+		return "", fmt.Errorf("Invalid token")
+	}
+	return uid, nil
+}
+
+// RoleRequiresMFA проверяет, обязательна ли 2FA для роли.
+// Список ролей задаётся MFA_REQUIRED_ROLES, по умолчанию ADMIN и MODERATOR.
+func RoleRequiresMFA(role string) bool {
+	roles := splitList(envOrDefault("MFA_REQUIRED_ROLES", RoleAdmin+","+RoleModerator))
+	for _, r := range roles {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
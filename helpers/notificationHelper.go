@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"oiynlike/database"
+	"oiynlike/models"
+	"oiynlike/pagination"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var notificationCollection *mongo.Collection = database.OpenCollection("notifications")
+
+// NotifyUsers создаёт одинаковое уведомление для каждого из пользователей userIDs
+func NotifyUsers(ctx context.Context, userIDs []string, notification models.Notification) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	documents := make([]interface{}, 0, len(userIDs))
+	for _, userID := range userIDs {
+		n := notification
+		n.ID = primitive.NewObjectID()
+		n.UserID = userID
+		n.Read = false
+		n.CreatedAt = now
+		documents = append(documents, n)
+	}
+
+	if _, err := notificationCollection.InsertMany(ctx, documents); err != nil {
+		return fmt.Errorf("error inserting notifications: %v", err)
+	}
+	return nil
+}
+
+// GetUserNotifications возвращает страницу уведомлений пользователя
+func GetUserNotifications(ctx context.Context, userID string, unreadOnly bool, params pagination.Params) (pagination.Page[models.Notification], error) {
+	filter := bson.M{"user_id": userID}
+	if unreadOnly {
+		filter["read"] = false
+	}
+
+	return pagination.Find[models.Notification](ctx, notificationCollection, filter, params)
+}
+
+// MarkNotificationRead отмечает уведомление прочитанным. Возвращает false, если
+// у пользователя нет такого уведомления.
+func MarkNotificationRead(ctx context.Context, userID string, notificationID primitive.ObjectID) (bool, error) {
+	result, err := notificationCollection.UpdateOne(ctx,
+		bson.M{"_id": notificationID, "user_id": userID},
+		bson.M{"$set": bson.M{"read": true}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
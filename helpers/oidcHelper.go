@@ -0,0 +1,280 @@
+package helpers
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+var ErrIDTokenInvalid = errors.New("id token is invalid")
+
+// OIDCProvider — внешний провайдер входа (Google, Apple), чьи ID-токены мы принимаем
+type OIDCProvider struct {
+	Name      string
+	Issuers   []string
+	ClientIDs []string
+	JWKSURL   string
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// OIDCClaims — данные пользователя из проверенного ID-токена
+type OIDCClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+	PhotoURL      string
+}
+
+const jwksCacheTTL = time.Hour
+
+var (
+	oidcProviders     map[string]*OIDCProvider
+	oidcProvidersOnce sync.Once
+	jwksHTTPClient    = &http.Client{Timeout: 10 * time.Second}
+)
+
+// envOrDefault возвращает значение переменной окружения или значение по умолчанию
+func envOrDefault(key string, value string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return value
+}
+
+// splitList разбирает список значений через запятую
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// loadOIDCProviders настраивает провайдеров из переменных окружения
+// OIDC_<PROVIDER>_CLIENT_IDS, OIDC_<PROVIDER>_ISSUERS и OIDC_<PROVIDER>_JWKS_URL.
+// Провайдер без client id отключён.
+func loadOIDCProviders() {
+	defaults := []struct {
+		Name    string
+		Issuers []string
+		JWKSURL string
+	}{
+		{
+			Name:    "google",
+			Issuers: []string{"https://accounts.google.com", "accounts.google.com"},
+			JWKSURL: "https://www.googleapis.com/oauth2/v3/certs",
+		},
+		{
+			Name:    "apple",
+			Issuers: []string{"https://appleid.apple.com"},
+			JWKSURL: "https://appleid.apple.com/auth/keys",
+		},
+	}
+
+	oidcProviders = make(map[string]*OIDCProvider)
+	for _, d := range defaults {
+		prefix := "OIDC_" + strings.ToUpper(d.Name) + "_"
+
+		clientIDs := splitList(os.Getenv(prefix + "CLIENT_IDS"))
+		if len(clientIDs) == 0 {
+			continue
+		}
+
+		issuers := d.Issuers
+		if configured := splitList(os.Getenv(prefix + "ISSUERS")); len(configured) > 0 {
+			issuers = configured
+		}
+
+		oidcProviders[d.Name] = &OIDCProvider{
+			Name:      d.Name,
+			Issuers:   issuers,
+			ClientIDs: clientIDs,
+			JWKSURL:   envOrDefault(prefix+"JWKS_URL", d.JWKSURL),
+		}
+	}
+}
+
+// GetOIDCProvider возвращает настроенного провайдера по имени
+func GetOIDCProvider(name string) (*OIDCProvider, bool) {
+	oidcProvidersOnce.Do(loadOIDCProviders)
+	provider, ok := oidcProviders[name]
+	return provider, ok
+}
+
+// VerifyIDToken проверяет подпись, издателя, аудиторию, срок действия и nonce ID-токена
+func (p *OIDCProvider) VerifyIDToken(idToken string, nonce string) (OIDCClaims, error) {
+	var result OIDCClaims
+
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(kid)
+	})
+	if err != nil || !token.Valid {
+		return result, ErrIDTokenInvalid
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return result, ErrIDTokenInvalid
+	}
+
+	issuer, _ := claims["iss"].(string)
+	if !containsString(p.Issuers, issuer) {
+		return result, ErrIDTokenInvalid
+	}
+
+	if !audienceMatches(claims["aud"], p.ClientIDs) {
+		return result, ErrIDTokenInvalid
+	}
+
+	if _, ok := claims["exp"]; !ok {
+		return result, ErrIDTokenInvalid
+	}
+
+	if nonce != "" {
+		if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+			return result, ErrIDTokenInvalid
+		}
+	}
+
+	result.Subject, _ = claims["sub"].(string)
+	if result.Subject == "" {
+		return result, ErrIDTokenInvalid
+	}
+
+	result.Email, _ = claims["email"].(string)
+	// Apple передаёт email_verified строкой
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = verified
+	case string:
+		result.EmailVerified = verified == "true"
+	}
+	result.FirstName, _ = claims["given_name"].(string)
+	result.LastName, _ = claims["family_name"].(string)
+	result.PhotoURL, _ = claims["picture"].(string)
+
+	return result, nil
+}
+
+// publicKey возвращает ключ из JWKS провайдера; при неизвестном kid набор ключей перечитывается
+func (p *OIDCProvider) publicKey(kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok && time.Since(p.fetchedAt) < jwksCacheTTL {
+		return key, nil
+	}
+
+	keys, err := fetchJWKS(p.JWKSURL)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.fetchedAt = time.Now()
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func fetchJWKS(url string) (map[string]*rsa.PublicKey, error) {
+	resp, err := jwksHTTPClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching jwks: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching jwks: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("error decoding jwks: %v", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		key, err := parseRSAPublicKey(k.N, k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func parseRSAPublicKey(n string, e string) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: int(new(big.Int).SetBytes(eBytes).Int64()),
+	}, nil
+}
+
+// audienceMatches проверяет claim aud, который может быть строкой или массивом
+func audienceMatches(aud interface{}, clientIDs []string) bool {
+	switch value := aud.(type) {
+	case string:
+		return containsString(clientIDs, value)
+	case []interface{}:
+		for _, item := range value {
+			if s, ok := item.(string); ok && containsString(clientIDs, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
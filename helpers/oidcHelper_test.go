@@ -0,0 +1,162 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+const (
+	testIssuer   = "https://issuer.test"
+	testClientID = "test-client"
+	testKeyID    = "test-key"
+)
+
+// testIssuerServer — локальный издатель ID-токенов: отдаёт JWKS с одним ключом
+type testIssuerServer struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	requests int
+}
+
+func newTestIssuer(t *testing.T) *testIssuerServer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &testIssuerServer{key: key}
+	issuer.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer.requests++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": testKeyID,
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+func (s *testIssuerServer) provider() *OIDCProvider {
+	return &OIDCProvider{
+		Name:      "google",
+		Issuers:   []string{testIssuer},
+		ClientIDs: []string{testClientID},
+		JWKSURL:   s.URL,
+	}
+}
+
+// validClaims — claims корректного ID-токена; тесты портят по одному полю
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            testIssuer,
+		"aud":            testClientID,
+		"sub":            "subject-1",
+		"email":          "player@example.com",
+		"email_verified": true,
+		"given_name":     "Aru",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+	}
+}
+
+func signIDToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyIDTokenValid(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	claims, err := issuer.provider().VerifyIDToken(signIDToken(t, issuer.key, testKeyID, validClaims()), "")
+	if err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	if claims.Subject != "subject-1" || claims.Email != "player@example.com" || !claims.EmailVerified || claims.FirstName != "Aru" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestVerifyIDTokenRejectsInvalidTokens(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	with := func(key string, value interface{}) jwt.MapClaims {
+		claims := validClaims()
+		claims[key] = value
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+	}{
+		{"bad signature", signIDToken(t, otherKey, testKeyID, validClaims()), ""},
+		{"wrong audience", signIDToken(t, issuer.key, testKeyID, with("aud", "other-client")), ""},
+		{"wrong issuer", signIDToken(t, issuer.key, testKeyID, with("iss", "https://evil.test")), ""},
+		{"expired", signIDToken(t, issuer.key, testKeyID, with("exp", time.Now().Add(-time.Minute).Unix())), ""},
+		{"unknown kid", signIDToken(t, issuer.key, "unknown-key", validClaims()), ""},
+		{"nonce mismatch", signIDToken(t, issuer.key, testKeyID, with("nonce", "a")), "b"},
+		{"no subject", signIDToken(t, issuer.key, testKeyID, with("sub", "")), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := issuer.provider().VerifyIDToken(tt.token, tt.nonce)
+			if !errors.Is(err, ErrIDTokenInvalid) {
+				t.Fatalf("expected ErrIDTokenInvalid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenAcceptsAudienceList(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	claims := validClaims()
+	claims["aud"] = []interface{}{"other-client", testClientID}
+	if _, err := issuer.provider().VerifyIDToken(signIDToken(t, issuer.key, testKeyID, claims), ""); err != nil {
+		t.Fatalf("token with audience list rejected: %v", err)
+	}
+}
+
+func TestVerifyIDTokenCachesKeys(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := issuer.provider()
+
+	for i := 0; i < 3; i++ {
+		if _, err := provider.VerifyIDToken(signIDToken(t, issuer.key, testKeyID, validClaims()), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if issuer.requests != 1 {
+		t.Fatalf("expected JWKS to be fetched once, got %d requests", issuer.requests)
+	}
+}
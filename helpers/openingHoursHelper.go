@@ -0,0 +1,68 @@
+package helpers
+
+import (
+	"fmt"
+	"time"
+)
+
+// ParseClock разбирает время суток "HH:MM" и возвращает число минут от полуночи.
+// "24:00" допускается как время закрытия в полночь.
+func ParseClock(value string) (int, error) {
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ValidateOpeningHours проверяет, что opening и closing — время суток "HH:MM"
+func ValidateOpeningHours(opening, closing string) error {
+	if _, err := ParseClock(opening); err != nil {
+		return fmt.Errorf("openingTime: %v", err)
+	}
+	if _, err := ParseClock(closing); err != nil {
+		return fmt.Errorf("closingTime: %v", err)
+	}
+	return nil
+}
+
+// CheckOpeningHours проверяет, что игра с start по end проходит, пока заведение открыто.
+// Часы работы задаются местным временем пояса location. Если closing не позже opening,
+// заведение работает через полночь (например, 18:00–04:00); равные значения — круглосуточно.
+func CheckOpeningHours(start, end time.Time, opening, closing string, location *time.Location) error {
+	openMinutes, err := ParseClock(opening)
+	if err != nil {
+		return err
+	}
+	closeMinutes, err := ParseClock(closing)
+	if err != nil {
+		return err
+	}
+	if openMinutes%(24*60) == closeMinutes%(24*60) {
+		return nil
+	}
+
+	local := start.In(location)
+	// Игра может начаться в часы работы, открывшиеся накануне (после полуночи ночного заведения)
+	for _, dayOffset := range []int{-1, 0} {
+		day := time.Date(local.Year(), local.Month(), local.Day()+dayOffset, 0, 0, 0, 0, location)
+		opensAt := time.Date(day.Year(), day.Month(), day.Day(), 0, openMinutes, 0, 0, location)
+		closesAt := time.Date(day.Year(), day.Month(), day.Day(), 0, closeMinutes, 0, 0, location)
+		if closeMinutes <= openMinutes {
+			closesAt = time.Date(day.Year(), day.Month(), day.Day()+1, 0, closeMinutes, 0, 0, location)
+		}
+
+		if start.Before(opensAt) || !start.Before(closesAt) {
+			continue
+		}
+		if end.After(closesAt) {
+			return fmt.Errorf("the game must end by the venue closing time %s", closing)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("scheduled_time is outside the venue opening hours %s–%s", opening, closing)
+}
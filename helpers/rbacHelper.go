@@ -0,0 +1,60 @@
+package helpers
+
+// Роли пользователей (поле user_type)
+const (
+	RoleAdmin      = "ADMIN"
+	RoleModerator  = "MODERATOR"
+	RoleVenueOwner = "VENUE_OWNER"
+	RoleUser       = "USER"
+)
+
+// Permission — право на действие, которое объявляется на маршруте
+type Permission string
+
+const (
+	PermGameCardsModerate Permission = "gamecards:moderate"
+	PermAnticafeRead      Permission = "anticafe:read"
+	PermAnticafeManage    Permission = "anticafe:manage"
+	PermUsersManageRoles  Permission = "users:manage_roles"
+	PermUsersUnlock       Permission = "users:unlock"
+	// PermManageAnyResource позволяет изменять ресурсы других пользователей
+	PermManageAnyResource Permission = "resources:manage_any"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleAdmin: {
+		PermGameCardsModerate,
+		PermAnticafeRead,
+		PermAnticafeManage,
+		PermUsersManageRoles,
+		PermUsersUnlock,
+		PermManageAnyResource,
+	},
+	RoleModerator: {
+		PermGameCardsModerate,
+		PermAnticafeRead,
+	},
+	RoleVenueOwner: {
+		PermAnticafeRead,
+		PermAnticafeManage,
+	},
+	RoleUser: {
+		PermAnticafeRead,
+	},
+}
+
+// IsValidRole проверяет, что роль известна системе
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission проверяет, что роль обладает правом permission
+func HasPermission(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
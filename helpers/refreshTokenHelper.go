@@ -0,0 +1,133 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"oiynlike/database"
+	"oiynlike/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var refreshTokenCollection *mongo.Collection = database.OpenCollection("refresh_tokens")
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
+// StoreRefreshToken регистрирует выданный refresh token, чтобы его можно было обменять ровно один раз
+func StoreRefreshToken(ctx context.Context, signedRefreshToken string) error {
+	claims, err := parseRefreshToken(signedRefreshToken)
+	if err != nil {
+		return err
+	}
+
+	tokenID, _ := claims["jti"].(string)
+	family, _ := claims["family"].(string)
+	uid, _ := claims["uid"].(string)
+	exp, _ := claims["exp"].(float64)
+
+	record := models.RefreshToken{
+		ID:        primitive.NewObjectID(),
+		TokenID:   tokenID,
+		Family:    family,
+		UserID:    uid,
+		ExpiresAt: time.Unix(int64(exp), 0),
+		CreatedAt: time.Now(),
+	}
+
+	_, err = refreshTokenCollection.InsertOne(ctx, record)
+	if err != nil {
+		return fmt.Errorf("error storing refresh token: %v", err)
+	}
+	return nil
+}
+
+// RotateRefreshToken обменивает refresh token на новую пару токенов.
+// Старый токен становится недействительным; повторное предъявление уже
+// обменянного токена отзывает всё его семейство.
+func RotateRefreshToken(ctx context.Context, signedRefreshToken string, userAgent string, ip string) (string, string, error) {
+	claims, err := ValidateRefreshToken(signedRefreshToken)
+	if err != nil {
+		return "", "", ErrRefreshTokenInvalid
+	}
+
+	tokenID, _ := claims["jti"].(string)
+	family, _ := claims["family"].(string)
+	uid, _ := claims["uid"].(string)
+	if tokenID == "" || family == "" || uid == "" {
+		return "", "", ErrRefreshTokenInvalid
+	}
+
+	// Помечаем токен использованным одной атомарной операцией,
+	// поэтому при параллельных запросах обменять его сможет только один
+	filter := bson.M{"jti": tokenID, "user_id": uid, "used": false, "revoked": false}
+	update := bson.M{"$set": bson.M{"used": true}}
+
+	var record models.RefreshToken
+	err = refreshTokenCollection.FindOneAndUpdate(ctx, filter, update).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		count, countErr := refreshTokenCollection.CountDocuments(ctx, bson.M{"jti": tokenID, "user_id": uid})
+		if countErr != nil {
+			return "", "", fmt.Errorf("error checking refresh token: %v", countErr)
+		}
+		if count == 0 {
+			return "", "", ErrRefreshTokenInvalid
+		}
+
+		// Токен уже был обменян или отозван — похоже на кражу, отзываем всё семейство
+		if err := RevokeTokenFamily(ctx, uid, family); err != nil {
+			return "", "", err
+		}
+		return "", "", ErrRefreshTokenReused
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("error retrieving refresh token: %v", err)
+	}
+
+	var user models.User
+	err = userCollection.FindOne(ctx, bson.M{"user_id": uid}).Decode(&user)
+	if err != nil {
+		return "", "", fmt.Errorf("error retrieving user: %v", err)
+	}
+
+	mfa, _ := claims["mfa"].(bool)
+	token, refreshToken, err := generateTokensForFamily(user.Email, user.FirstName, user.LastName, user.UserType, user.UserId, family, mfa)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := UpdateAllTokens(ctx, token, refreshToken, uid); err != nil {
+		return "", "", err
+	}
+
+	if err := extendSession(ctx, family, refreshToken, userAgent, ip); err != nil {
+		return "", "", err
+	}
+
+	return token, refreshToken, nil
+}
+
+// RevokeTokenFamily отзывает все refresh-токены семейства и завершает его сессию,
+// после чего токены доступа этой сессии тоже перестают приниматься
+func RevokeTokenFamily(ctx context.Context, userId string, family string) error {
+	filter := bson.M{"user_id": userId, "family": family}
+	update := bson.M{"$set": bson.M{"revoked": true}}
+
+	_, err := refreshTokenCollection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("error revoking refresh token family: %v", err)
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(family)
+	if err != nil {
+		return nil
+	}
+	return deleteSessions(ctx, bson.M{"_id": sessionID, "user_id": userId})
+}
@@ -0,0 +1,146 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"oiynlike/database"
+	"oiynlike/models"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var revokedTokenCollection *mongo.Collection = database.OpenCollection("revoked_tokens")
+
+// RevokeToken добавляет токен доступа в список отозванных до истечения его срока действия
+func RevokeToken(ctx context.Context, tokenID string, userId string, expiresAt time.Time) error {
+	if tokenID == "" {
+		return fmt.Errorf("token has no jti")
+	}
+
+	record := models.RevokedToken{
+		ID:        primitive.NewObjectID(),
+		TokenID:   tokenID,
+		UserID:    userId,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	_, err := revokedTokenCollection.InsertOne(ctx, record)
+	if mongo.IsDuplicateKeyError(err) {
+		// Токен уже отозван
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error revoking token: %v", err)
+	}
+	return nil
+}
+
+// RevokeAllTokens делает недействительными все токены пользователя на всех устройствах
+func RevokeAllTokens(ctx context.Context, userId string) error {
+	// Новая версия токенов отсекает все ранее выданные токены доступа
+	_, err := userCollection.UpdateOne(ctx,
+		bson.M{"user_id": userId},
+		bson.M{"$inc": bson.M{"token_version": 1}, "$set": bson.M{"updated_at": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating token version: %v", err)
+	}
+
+	_, err = refreshTokenCollection.UpdateMany(ctx, bson.M{"user_id": userId}, bson.M{"$set": bson.M{"revoked": true}})
+	if err != nil {
+		return fmt.Errorf("error revoking refresh tokens: %v", err)
+	}
+
+	return deleteSessions(ctx, bson.M{"user_id": userId})
+}
+
+// currentTokenVersion возвращает текущую версию токенов пользователя
+func currentTokenVersion(userId string) (int, error) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var user models.User
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		// Пользователь ещё не сохранён (регистрация)
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error retrieving token version: %v", err)
+	}
+	return user.TokenVersion, nil
+}
+
+// checkTokenRevoked проверяет, что токен не отозван ни по jti, ни сменой версии токенов
+func checkTokenRevoked(claims jwt.MapClaims) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if tokenID, ok := claims["jti"].(string); ok && tokenID != "" {
+		count, err := revokedTokenCollection.CountDocuments(ctx, bson.M{"jti": tokenID})
+		if err != nil {
+			return fmt.Errorf("Failed to check token revocation: %v", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("Token has been revoked")
+		}
+	}
+
+	uid, _ := claims["uid"].(string)
+
+	// Токен принадлежит сессии устройства, которую могли завершить
+	if family, ok := claims["family"].(string); ok && family != "" {
+		exists, err := sessionExists(ctx, uid, family)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("Session has been revoked")
+		}
+	}
+
+	version, err := currentTokenVersion(uid)
+	if err != nil {
+		return err
+	}
+
+	tokenVersion, _ := claims["ver"].(float64)
+	if int(tokenVersion) != version {
+		return fmt.Errorf("Token has been revoked")
+	}
+
+	return nil
+}
+
+// EnsureTokenIndexes создаёт уникальные индексы по jti, по которым токены проверяются
+// на каждом запросе, и TTL-индексы: MongoDB сама удаляет записи, срок действия которых (expires_at) истёк
+func EnsureTokenIndexes(ctx context.Context) error {
+	for _, collection := range []*mongo.Collection{revokedTokenCollection, refreshTokenCollection} {
+		_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "jti", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			return fmt.Errorf("error creating jti index on %s: %v", collection.Name(), err)
+		}
+	}
+
+	for _, collection := range []*mongo.Collection{revokedTokenCollection, sessionCollection, refreshTokenCollection} {
+		_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+		if err != nil {
+			return fmt.Errorf("error creating TTL index on %s: %v", collection.Name(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"oiynlike/database"
+	"oiynlike/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var roleChangeCollection *mongo.Collection = database.OpenCollection("role_changes")
+
+var ErrUserNotFound = errors.New("user not found")
+
+// Источники изменения роли для аудита
+const (
+	RoleChangeSourceAPI = "api"
+	RoleChangeSourceCLI = "cli"
+)
+
+// ChangeUserRole меняет роль пользователя, записывает изменение в аудит и
+// отзывает выданные токены, чтобы новая роль вступила в силу сразу
+func ChangeUserRole(ctx context.Context, userId string, newRole string, changedBy string, source string) (models.RoleChange, error) {
+	var change models.RoleChange
+
+	if !IsValidRole(newRole) {
+		return change, fmt.Errorf("unknown role %q", newRole)
+	}
+
+	// Меняем роль атомарно и получаем предыдущее значение
+	var previous models.User
+	err := userCollection.FindOneAndUpdate(ctx,
+		bson.M{"user_id": userId},
+		bson.M{"$set": bson.M{"user_type": newRole, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		return change, ErrUserNotFound
+	}
+	if err != nil {
+		return change, fmt.Errorf("error updating user role: %v", err)
+	}
+
+	change = models.RoleChange{
+		ID:        primitive.NewObjectID(),
+		UserID:    userId,
+		OldRole:   previous.UserType,
+		NewRole:   newRole,
+		ChangedBy: changedBy,
+		Source:    source,
+		CreatedAt: time.Now(),
+	}
+
+	_, err = roleChangeCollection.InsertOne(ctx, change)
+	if err != nil {
+		return change, fmt.Errorf("error recording role change: %v", err)
+	}
+
+	if previous.UserType != newRole {
+		if err := RevokeAllTokens(ctx, userId); err != nil {
+			return change, err
+		}
+	}
+
+	return change, nil
+}
@@ -0,0 +1,173 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRecurrenceIterations ограничивает перебор периодов правила, чтобы
+// ошибочное правило не зациклило генерацию
+const maxRecurrenceIterations = 5000
+
+// RRule — поддерживаемое подмножество правила повторения RFC 5545:
+// FREQ=DAILY|WEEKLY|MONTHLY, INTERVAL, COUNT, UNTIL и BYDAY (только для WEEKLY)
+type RRule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []time.Weekday
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// ParseRRule разбирает строку вида "FREQ=WEEKLY;BYDAY=TH;COUNT=10" (префикс "RRULE:" допускается)
+func ParseRRule(value string) (*RRule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return nil, fmt.Errorf("empty recurrence rule")
+	}
+
+	rule := &RRule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			switch strings.ToUpper(val) {
+			case "DAILY", "WEEKLY", "MONTHLY":
+				rule.Freq = strings.ToUpper(val)
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", val)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL %q", val)
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(val)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("invalid COUNT %q", val)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseRRuleTime(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UNTIL %q", val)
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(strings.ToUpper(val), ",") {
+				weekday, ok := rruleWeekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("unsupported BYDAY value %q", day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part %q", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("recurrence rule has no FREQ")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, fmt.Errorf("COUNT and UNTIL cannot be used together")
+	}
+	if len(rule.ByDay) > 0 && rule.Freq != "WEEKLY" {
+		return nil, fmt.Errorf("BYDAY is supported only with FREQ=WEEKLY")
+	}
+
+	return rule, nil
+}
+
+func parseRRuleTime(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	return time.Parse("20060102", value)
+}
+
+// Occurrences возвращает начала повторений правила, начиная с dtstart и не позже until.
+// Первое повторение — сам dtstart, если он подходит под правило.
+func (r *RRule) Occurrences(dtstart, until time.Time) []time.Time {
+	if !r.Until.IsZero() && r.Until.Before(until) {
+		until = r.Until
+	}
+
+	var occurrences []time.Time
+	emitted := 0
+	emit := func(t time.Time) bool {
+		if t.Before(dtstart) {
+			return true
+		}
+		if t.After(until) || (r.Count > 0 && emitted >= r.Count) {
+			return false
+		}
+		occurrences = append(occurrences, t)
+		emitted++
+		return true
+	}
+
+	for period := 0; period < maxRecurrenceIterations; period++ {
+		var candidates []time.Time
+		switch r.Freq {
+		case "DAILY":
+			candidates = []time.Time{dtstart.AddDate(0, 0, period*r.Interval)}
+		case "MONTHLY":
+			t := dtstart.AddDate(0, period*r.Interval, 0)
+			// Несуществующие даты (31 число в коротком месяце) пропускаются, как в RFC 5545
+			if t.Day() == dtstart.Day() {
+				candidates = []time.Time{t}
+			}
+		case "WEEKLY":
+			candidates = r.weekCandidates(dtstart, period)
+		}
+
+		for _, t := range candidates {
+			if !emit(t) {
+				return occurrences
+			}
+		}
+
+		if len(candidates) > 0 && candidates[len(candidates)-1].After(until) {
+			return occurrences
+		}
+	}
+
+	return occurrences
+}
+
+// weekCandidates возвращает дни BYDAY недели номер period (недели начинаются с понедельника)
+func (r *RRule) weekCandidates(dtstart time.Time, period int) []time.Time {
+	if len(r.ByDay) == 0 {
+		return []time.Time{dtstart.AddDate(0, 0, 7*period*r.Interval)}
+	}
+
+	offset := (int(dtstart.Weekday()) + 6) % 7
+	weekStart := dtstart.AddDate(0, 0, 7*period*r.Interval-offset)
+
+	candidates := make([]time.Time, 0, len(r.ByDay))
+	for _, day := range r.ByDay {
+		candidates = append(candidates, weekStart.AddDate(0, 0, (int(day)+6)%7))
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	return candidates
+}
@@ -0,0 +1,197 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"oiynlike/database"
+	"oiynlike/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var sessionCollection *mongo.Collection = database.OpenCollection("sessions")
+
+// sessionTouchInterval — не чаще этого обновляем last_seen_at, чтобы не писать в базу на каждый запрос
+const sessionTouchInterval = time.Minute
+
+// sessionTouches помнит, когда процесс последний раз обновлял каждую сессию,
+// чтобы не обращаться к базе чаще sessionTouchInterval
+var sessionTouches = struct {
+	sync.Mutex
+	at map[string]time.Time
+}{at: map[string]time.Time{}}
+
+// maxSessionTouches — после стольких запомненных сессий устаревшие записи удаляются
+const maxSessionTouches = 10000
+
+// CreateSession заводит сессию устройства для только что выданной пары токенов
+func CreateSession(ctx context.Context, signedRefreshToken string, userAgent string, ip string) error {
+	claims, err := parseRefreshToken(signedRefreshToken)
+	if err != nil {
+		return err
+	}
+
+	family, _ := claims["family"].(string)
+	uid, _ := claims["uid"].(string)
+	exp, _ := claims["exp"].(float64)
+
+	sessionID, err := primitive.ObjectIDFromHex(family)
+	if err != nil {
+		return fmt.Errorf("invalid token family: %v", err)
+	}
+
+	session := models.Session{
+		ID:         sessionID,
+		UserID:     uid,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  time.Now(),
+		LastSeenAt: time.Now(),
+		ExpiresAt:  sessionExpiresAt(exp, time.Now()),
+	}
+
+	_, err = sessionCollection.InsertOne(ctx, session)
+	if err != nil {
+		return fmt.Errorf("error creating session: %v", err)
+	}
+	return nil
+}
+
+// sessionExpiresAt — когда сессию можно удалить: после refresh-токена с exp refreshExp
+// и токена доступа, выданного вместе с ним в момент now, смотря что истекает позже
+func sessionExpiresAt(refreshExp float64, now time.Time) time.Time {
+	expiresAt := time.Unix(int64(refreshExp), 0)
+	if accessExp := now.Add(accessTokenLifetime); accessExp.After(expiresAt) {
+		return accessExp
+	}
+	return expiresAt
+}
+
+// DeleteSession удаляет сессию, заведённую CreateSession для signedRefreshToken,
+// если вход не удалось завершить
+func DeleteSession(ctx context.Context, signedRefreshToken string) error {
+	claims, err := parseRefreshToken(signedRefreshToken)
+	if err != nil {
+		return err
+	}
+
+	family, _ := claims["family"].(string)
+	sessionID, err := primitive.ObjectIDFromHex(family)
+	if err != nil {
+		return fmt.Errorf("invalid token family: %v", err)
+	}
+	return deleteSessions(ctx, bson.M{"_id": sessionID})
+}
+
+// recentlyTouched сообщает, что сессию family уже обновляли за последние sessionTouchInterval,
+// и запоминает время обновления
+func recentlyTouched(family string, now time.Time) bool {
+	sessionTouches.Lock()
+	defer sessionTouches.Unlock()
+
+	if at, ok := sessionTouches.at[family]; ok && now.Sub(at) < sessionTouchInterval {
+		return true
+	}
+
+	if len(sessionTouches.at) >= maxSessionTouches {
+		for key, at := range sessionTouches.at {
+			if now.Sub(at) >= sessionTouchInterval {
+				delete(sessionTouches.at, key)
+			}
+		}
+	}
+	sessionTouches.at[family] = now
+	return false
+}
+
+// TouchSession обновляет время последней активности и адрес устройства
+func TouchSession(ctx context.Context, family string, userAgent string, ip string) error {
+	sessionID, err := primitive.ObjectIDFromHex(family)
+	if err != nil {
+		return nil
+	}
+
+	now := time.Now()
+	if recentlyTouched(family, now) {
+		return nil
+	}
+
+	_, err = sessionCollection.UpdateOne(ctx,
+		bson.M{"_id": sessionID, "last_seen_at": bson.M{"$lt": now.Add(-sessionTouchInterval)}},
+		bson.M{"$set": bson.M{"last_seen_at": now, "ip": ip, "user_agent": userAgent}},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating session: %v", err)
+	}
+	return nil
+}
+
+// extendSession продлевает сессию до срока действия нового refresh-токена и отмечает активность устройства
+func extendSession(ctx context.Context, family string, signedRefreshToken string, userAgent string, ip string) error {
+	sessionID, err := primitive.ObjectIDFromHex(family)
+	if err != nil {
+		return nil
+	}
+
+	claims, err := parseRefreshToken(signedRefreshToken)
+	if err != nil {
+		return err
+	}
+	exp, _ := claims["exp"].(float64)
+
+	_, err = sessionCollection.UpdateOne(ctx,
+		bson.M{"_id": sessionID},
+		bson.M{"$set": bson.M{"expires_at": sessionExpiresAt(exp, time.Now()), "last_seen_at": time.Now(), "ip": ip, "user_agent": userAgent}},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating session: %v", err)
+	}
+	return nil
+}
+
+// GetUserSessions возвращает активные сессии пользователя, начиная с последней активной
+func GetUserSessions(ctx context.Context, userId string) ([]models.Session, error) {
+	cursor, err := sessionCollection.Find(ctx,
+		bson.M{"user_id": userId},
+		options.Find().SetSort(bson.D{{Key: "last_seen_at", Value: -1}}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving sessions: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []models.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, fmt.Errorf("error decoding sessions: %v", err)
+	}
+	return sessions, nil
+}
+
+// sessionExists проверяет, что сессия с id family не отозвана
+func sessionExists(ctx context.Context, userId string, family string) (bool, error) {
+	sessionID, err := primitive.ObjectIDFromHex(family)
+	if err != nil {
+		return false, nil
+	}
+
+	count, err := sessionCollection.CountDocuments(ctx, bson.M{"_id": sessionID, "user_id": userId})
+	if err != nil {
+		return false, fmt.Errorf("Failed to check session: %v", err)
+	}
+	return count > 0, nil
+}
+
+// deleteSessions удаляет сессии по фильтру; токены удалённых сессий перестают приниматься
+func deleteSessions(ctx context.Context, filter bson.M) error {
+	_, err := sessionCollection.DeleteMany(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("error deleting sessions: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRecentlyTouched(t *testing.T) {
+	family := primitive.NewObjectID().Hex()
+	now := time.Now()
+
+	if recentlyTouched(family, now) {
+		t.Fatal("new session is reported as touched")
+	}
+	if !recentlyTouched(family, now.Add(sessionTouchInterval/2)) {
+		t.Fatal("session is touched again within the interval")
+	}
+	if recentlyTouched(family, now.Add(sessionTouchInterval)) {
+		t.Fatal("session is not touched after the interval")
+	}
+	if recentlyTouched(primitive.NewObjectID().Hex(), now) {
+		t.Fatal("sessions share the touch time")
+	}
+}
+
+func TestSessionOutlivesAccessToken(t *testing.T) {
+	now := time.Now()
+
+	refreshExp := float64(now.Add(refreshTokenLifetime).Unix())
+	if got := sessionExpiresAt(refreshExp, now); got.Before(now.Add(accessTokenLifetime)) {
+		t.Fatalf("session expires at %v, before the access token", got)
+	}
+
+	laterExp := now.Add(2 * accessTokenLifetime).Truncate(time.Second)
+	if got := sessionExpiresAt(float64(laterExp.Unix()), now); !got.Equal(laterExp) {
+		t.Fatalf("expected session to expire with the refresh token at %v, got %v", laterExp, got)
+	}
+}
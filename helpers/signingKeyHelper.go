@@ -0,0 +1,436 @@
+package helpers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"oiynlike/database"
+	"oiynlike/models"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var signingKeyCollection *mongo.Collection = database.OpenCollection("signing_keys")
+
+// maxTokenLifetime — самый долгий срок жизни выдаваемых токенов (токен доступа).
+// Столько же ключ остаётся в JWKS после того, как перестал подписывать.
+const maxTokenLifetime = 30 * 24 * time.Hour
+
+// keyRingReloadInterval — как часто перечитывать ключи, созданные другими экземплярами
+const keyRingReloadInterval = time.Minute
+
+// keyRingMissReloadInterval — не чаще этого ключи перечитываются из-за токена с неизвестным kid,
+// чтобы поток токенов с выдуманными kid не превращался в поток запросов к базе
+const keyRingMissReloadInterval = 10 * time.Second
+
+// maxUnknownKeyIDs — после стольких запомненных неизвестных kid устаревшие записи удаляются
+const maxUnknownKeyIDs = 1000
+
+type signingKey struct {
+	kid        string
+	method     jwt.SigningMethod
+	privateKey crypto.Signer
+	publicKey  crypto.PublicKey
+	createdAt  time.Time
+	retiresAt  time.Time
+	expiresAt  time.Time
+}
+
+type keyRing struct {
+	mu       sync.RWMutex
+	keys     []*signingKey // от новых к старым
+	loadedAt time.Time
+
+	// missMu защищает учёт перечитываний из-за неизвестных kid
+	missMu       sync.Mutex
+	missReloadAt time.Time
+	// unknown — kid, которых не нашлось и после перечитывания, и когда это выяснилось
+	unknown map[string]time.Time
+}
+
+var signingKeys = &keyRing{}
+
+// signingAlgorithm возвращает алгоритм для новых ключей: RS256 (по умолчанию) или EdDSA
+func signingAlgorithm() string {
+	if envOrDefault("JWT_SIGNING_ALG", "RS256") == "EdDSA" {
+		return "EdDSA"
+	}
+	return "RS256"
+}
+
+// keyRotationInterval возвращает период ротации ключей из JWT_KEY_ROTATION_INTERVAL
+func keyRotationInterval() time.Duration {
+	interval, err := time.ParseDuration(envOrDefault("JWT_KEY_ROTATION_INTERVAL", "720h"))
+	if err != nil || interval <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return interval
+}
+
+// signToken подписывает claims текущим ключом и указывает его kid в заголовке
+func signToken(claims jwt.MapClaims) (string, error) {
+	key, err := signingKeys.current()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.privateKey)
+}
+
+// verificationKey возвращает открытый ключ для проверки токена строго
+// по его kid и алгоритму
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("token has no kid")
+	}
+
+	key, err := signingKeys.find(kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+
+	return key.publicKey, nil
+}
+
+func (r *keyRing) current() (*signingKey, error) {
+	if err := r.reloadIfStale(); err != nil {
+		return nil, err
+	}
+
+	if key := r.newestSigningKey(); key != nil {
+		return key, nil
+	}
+
+	// Действующих ключей нет (первый запуск или долгий простой) — создаём ключ сразу
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if err := RotateSigningKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	if key := r.newestSigningKey(); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("no active signing key")
+}
+
+func (r *keyRing) newestSigningKey() *signingKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	for _, key := range r.keys {
+		if now.Before(key.retiresAt) {
+			return key
+		}
+	}
+	return nil
+}
+
+func (r *keyRing) find(kid string) (*signingKey, error) {
+	if err := r.reloadIfStale(); err != nil {
+		return nil, err
+	}
+
+	if key := r.lookup(kid); key != nil {
+		return key, nil
+	}
+
+	// Ключ мог только что появиться на другом экземпляре
+	if r.shouldReloadFor(kid) {
+		if err := r.reload(); err != nil {
+			return nil, err
+		}
+		if key := r.lookup(kid); key != nil {
+			return key, nil
+		}
+		r.rememberUnknown(kid)
+	}
+
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// shouldReloadFor решает, перечитывать ли ключи ради неизвестного kid: не чаще
+// keyRingMissReloadInterval и не ради kid, которого недавно уже не нашлось
+func (r *keyRing) shouldReloadFor(kid string) bool {
+	r.missMu.Lock()
+	defer r.missMu.Unlock()
+
+	now := time.Now()
+	if missedAt, ok := r.unknown[kid]; ok && now.Sub(missedAt) < keyRingReloadInterval {
+		return false
+	}
+	if now.Sub(r.missReloadAt) < keyRingMissReloadInterval {
+		return false
+	}
+	r.missReloadAt = now
+	return true
+}
+
+// rememberUnknown запоминает kid, которого нет и в свежей копии ключей
+func (r *keyRing) rememberUnknown(kid string) {
+	r.missMu.Lock()
+	defer r.missMu.Unlock()
+
+	now := time.Now()
+	if r.unknown == nil {
+		r.unknown = map[string]time.Time{}
+	}
+	if len(r.unknown) >= maxUnknownKeyIDs {
+		for key, missedAt := range r.unknown {
+			if now.Sub(missedAt) >= keyRingReloadInterval {
+				delete(r.unknown, key)
+			}
+		}
+	}
+	r.unknown[kid] = now
+}
+
+func (r *keyRing) lookup(kid string) *signingKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	for _, key := range r.keys {
+		if key.kid == kid && now.Before(key.expiresAt) {
+			return key
+		}
+	}
+	return nil
+}
+
+func (r *keyRing) reloadIfStale() error {
+	r.mu.RLock()
+	stale := time.Since(r.loadedAt) > keyRingReloadInterval
+	r.mu.RUnlock()
+
+	if !stale {
+		return nil
+	}
+	return r.reload()
+}
+
+// reload перечитывает неистёкшие ключи из базы данных
+func (r *keyRing) reload() error {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	cursor, err := signingKeyCollection.Find(ctx, bson.M{"expires_at": bson.M{"$gt": time.Now()}})
+	if err != nil {
+		return fmt.Errorf("error loading signing keys: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var records []models.SigningKey
+	if err := cursor.All(ctx, &records); err != nil {
+		return fmt.Errorf("error decoding signing keys: %v", err)
+	}
+
+	keys := make([]*signingKey, 0, len(records))
+	for _, record := range records {
+		key, err := decodeSigningKey(record)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", record.KeyID, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].createdAt.After(keys[j].createdAt)
+	})
+
+	r.mu.Lock()
+	r.keys = keys
+	r.loadedAt = time.Now()
+	r.mu.Unlock()
+
+	return nil
+}
+
+func decodeSigningKey(record models.SigningKey) (*signingKey, error) {
+	block, _ := pem.Decode([]byte(record.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key PEM")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{
+		kid:       record.KeyID,
+		createdAt: record.CreatedAt,
+		retiresAt: record.RetiresAt,
+		expiresAt: record.ExpiresAt,
+	}
+
+	switch privateKey := parsed.(type) {
+	case *rsa.PrivateKey:
+		if record.Algorithm != jwt.SigningMethodRS256.Alg() {
+			return nil, fmt.Errorf("algorithm %s doesn't match RSA key", record.Algorithm)
+		}
+		key.method = jwt.SigningMethodRS256
+		key.privateKey = privateKey
+		key.publicKey = &privateKey.PublicKey
+	case ed25519.PrivateKey:
+		if record.Algorithm != SigningMethodEdDSA.Alg() {
+			return nil, fmt.Errorf("algorithm %s doesn't match Ed25519 key", record.Algorithm)
+		}
+		key.method = SigningMethodEdDSA
+		key.privateKey = privateKey
+		key.publicKey = privateKey.Public()
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return key, nil
+}
+
+// RotateSigningKeys создаёт новый ключ, если самому свежему больше периода ротации,
+// и удаляет ключи, которыми уже нельзя проверить ни один токен
+func RotateSigningKeys(ctx context.Context) error {
+	if err := signingKeys.reload(); err != nil {
+		return err
+	}
+
+	interval := keyRotationInterval()
+
+	signingKeys.mu.RLock()
+	needsKey := len(signingKeys.keys) == 0 || time.Since(signingKeys.keys[0].createdAt) >= interval
+	signingKeys.mu.RUnlock()
+
+	if needsKey {
+		record, err := generateSigningKey(signingAlgorithm(), interval)
+		if err != nil {
+			return err
+		}
+
+		if _, err := signingKeyCollection.InsertOne(ctx, record); err != nil {
+			return fmt.Errorf("error storing signing key: %v", err)
+		}
+		log.Printf("Generated %s signing key %s", record.Algorithm, record.KeyID)
+	}
+
+	_, err := signingKeyCollection.DeleteMany(ctx, bson.M{"expires_at": bson.M{"$lte": time.Now()}})
+	if err != nil {
+		return fmt.Errorf("error deleting expired signing keys: %v", err)
+	}
+
+	return signingKeys.reload()
+}
+
+func generateSigningKey(algorithm string, interval time.Duration) (models.SigningKey, error) {
+	var privateKey crypto.Signer
+	var err error
+
+	switch algorithm {
+	case SigningMethodEdDSA.Alg():
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return models.SigningKey{}, fmt.Errorf("error generating signing key: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return models.SigningKey{}, fmt.Errorf("error encoding signing key: %v", err)
+	}
+
+	now := time.Now()
+	// Ключ подписывает два периода ротации, чтобы новый успел появиться до его отставки
+	retiresAt := now.Add(2 * interval)
+
+	return models.SigningKey{
+		ID:         primitive.NewObjectID(),
+		KeyID:      primitive.NewObjectID().Hex(),
+		Algorithm:  algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		CreatedAt:  now,
+		RetiresAt:  retiresAt,
+		ExpiresAt:  retiresAt.Add(maxTokenLifetime),
+	}, nil
+}
+
+// StartKeyRotation периодически проверяет, не пора ли сменить ключ подписи
+func StartKeyRotation(checkInterval time.Duration) {
+	go func() {
+		for {
+			var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+			if err := RotateSigningKeys(ctx); err != nil {
+				log.Printf("Error rotating signing keys: %v", err)
+			}
+			cancel()
+
+			time.Sleep(checkInterval)
+		}
+	}()
+}
+
+// PublicJWKS возвращает открытые ключи в формате JWK Set (RFC 7517)
+func PublicJWKS() ([]map[string]string, error) {
+	if err := signingKeys.reloadIfStale(); err != nil {
+		return nil, err
+	}
+
+	signingKeys.mu.RLock()
+	defer signingKeys.mu.RUnlock()
+
+	now := time.Now()
+	keys := make([]map[string]string, 0, len(signingKeys.keys))
+	for _, key := range signingKeys.keys {
+		if !now.Before(key.expiresAt) {
+			continue
+		}
+
+		jwk := map[string]string{
+			"kid": key.kid,
+			"alg": key.method.Alg(),
+			"use": "sig",
+		}
+
+		switch publicKey := key.publicKey.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(publicKey)
+		default:
+			continue
+		}
+
+		keys = append(keys, jwk)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,43 @@
+package helpers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestKeyRingThrottlesReloadsForUnknownKeyIDs(t *testing.T) {
+	var ring keyRing
+
+	if !ring.shouldReloadFor("kid-1") {
+		t.Fatal("first unknown kid should trigger a reload")
+	}
+	if ring.shouldReloadFor("kid-2") {
+		t.Fatal("second unknown kid within the interval should not trigger a reload")
+	}
+
+	// Интервал прошёл, но kid-1 уже не нашёлся после перечитывания
+	ring.rememberUnknown("kid-1")
+	ring.missReloadAt = time.Now().Add(-keyRingMissReloadInterval)
+	if ring.shouldReloadFor("kid-1") {
+		t.Fatal("recently missed kid should not trigger a reload")
+	}
+	if !ring.shouldReloadFor("kid-2") {
+		t.Fatal("another kid after the interval should trigger a reload")
+	}
+}
+
+func TestKeyRingForgetsStaleUnknownKeyIDs(t *testing.T) {
+	var ring keyRing
+
+	stale := time.Now().Add(-keyRingReloadInterval)
+	ring.unknown = map[string]time.Time{}
+	for i := 0; i < maxUnknownKeyIDs; i++ {
+		ring.unknown[fmt.Sprintf("kid-%d", i)] = stale
+	}
+
+	ring.rememberUnknown("fresh")
+	if len(ring.unknown) != 1 {
+		t.Fatalf("expected stale kids to be pruned, got %d entries", len(ring.unknown))
+	}
+}
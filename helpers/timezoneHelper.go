@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	// Встроенная база часовых поясов: сервер не зависит от tzdata в системе
+	_ "time/tzdata"
+)
+
+// fallbackTimeZone используется, если город неизвестен и DEFAULT_TIME_ZONE не задан
+const fallbackTimeZone = "Asia/Almaty"
+
+// cityTimeZones — часовые пояса городов, в которых проводятся игры
+var cityTimeZones = map[string]string{
+	"алматы":           "Asia/Almaty",
+	"almaty":           "Asia/Almaty",
+	"астана":           "Asia/Almaty",
+	"astana":           "Asia/Almaty",
+	"шымкент":          "Asia/Almaty",
+	"shymkent":         "Asia/Almaty",
+	"караганда":        "Asia/Almaty",
+	"karaganda":        "Asia/Almaty",
+	"усть-каменогорск": "Asia/Almaty",
+	"ust-kamenogorsk":  "Asia/Almaty",
+	"павлодар":         "Asia/Almaty",
+	"pavlodar":         "Asia/Almaty",
+	"костанай":         "Asia/Qostanay",
+	"kostanay":         "Asia/Qostanay",
+	"кызылорда":        "Asia/Qyzylorda",
+	"kyzylorda":        "Asia/Qyzylorda",
+	"актобе":           "Asia/Aqtobe",
+	"aktobe":           "Asia/Aqtobe",
+	"актау":            "Asia/Aqtau",
+	"aktau":            "Asia/Aqtau",
+	"атырау":           "Asia/Atyrau",
+	"atyrau":           "Asia/Atyrau",
+	"уральск":          "Asia/Oral",
+	"oral":             "Asia/Oral",
+	"uralsk":           "Asia/Oral",
+}
+
+// DefaultTimeZone возвращает часовой пояс города или DEFAULT_TIME_ZONE, если город неизвестен
+func DefaultTimeZone(city string) string {
+	if zone, ok := cityTimeZones[strings.ToLower(strings.TrimSpace(city))]; ok {
+		return zone
+	}
+	return envOrDefault("DEFAULT_TIME_ZONE", fallbackTimeZone)
+}
+
+// LoadTimeZone проверяет имя часового пояса IANA (например "Asia/Almaty") и загружает его
+func LoadTimeZone(name string) (*time.Location, error) {
+	// time.LoadLocation принимает "" и "Local", но это не пояса IANA
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("invalid time zone %q", name)
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q", name)
+	}
+	return location, nil
+}
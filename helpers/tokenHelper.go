@@ -3,9 +3,7 @@ package helpers
 import (
 	"context"
 	"fmt"
-	"log"
 	"oiynlike/database"
-	"os"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
@@ -13,39 +11,61 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var userCollection *mongo.Collection = database.OpenCollection("users")
 
-var SECRET_KEY []byte = []byte(os.Getenv("SECRET_KEY"))
+const (
+	accessTokenLifetime  = time.Hour * 24 * 30 // Токен действителен в течение 30 дней
+	refreshTokenLifetime = time.Hour * 24 * 7  // Refresh token действителен в течение 7 дней
+)
 
-// GenerateAllTokens генерирует токен и refresh token
+// GenerateAllTokens генерирует токен и refresh token, открывая новое семейство refresh-токенов
 func GenerateAllTokens(email, firstName, lastName, userType, uid string) (string, string, error) {
+	return generateTokensForFamily(email, firstName, lastName, userType, uid, primitive.NewObjectID().Hex(), false)
+}
+
+// GenerateAllTokensWithMFA генерирует пару токенов для входа, подтверждённого вторым фактором
+func GenerateAllTokensWithMFA(email, firstName, lastName, userType, uid string) (string, string, error) {
+	return generateTokensForFamily(email, firstName, lastName, userType, uid, primitive.NewObjectID().Hex(), true)
+}
+
+// generateTokensForFamily генерирует пару токенов, в которой refresh token принадлежит семейству family.
+// mfa отмечает, что вход подтверждён вторым фактором; отметка сохраняется при обмене refresh token.
+func generateTokensForFamily(email, firstName, lastName, userType, uid, family string, mfa bool) (string, string, error) {
+	// Версия токенов пользователя: увеличивается при выходе со всех устройств
+	version, err := currentTokenVersion(uid)
+	if err != nil {
+		return "", "", err
+	}
+
 	// Создание токена
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	tokenString, err := signToken(jwt.MapClaims{
 		"email":     email,
 		"firstName": firstName,
 		"lastName":  lastName,
 		"userType":  userType,
 		"uid":       uid,
-		"exp":       time.Now().Add(time.Hour * 24 * 30).Unix(), // Токен действителен в течение 1 часа
+		"jti":       primitive.NewObjectID().Hex(),
+		"family":    family,
+		"ver":       version,
+		"mfa":       mfa,
+		"exp":       time.Now().Add(accessTokenLifetime).Unix(),
 	})
-
-	// Подпись токена
-	tokenString, err := token.SignedString(SECRET_KEY)
 	if err != nil {
 		return "", "", err
 	}
 
-	// Создание refresh token
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"uid": uid,
-		"exp": time.Now().Add(time.Hour * 24 * 7).Unix(), // Refresh token действителен в течение 7 дней
+	// Создание и подпись refresh token
+	refreshTokenString, err := signToken(jwt.MapClaims{
+		"uid":    uid,
+		"jti":    primitive.NewObjectID().Hex(),
+		"family": family,
+		"ver":    version,
+		"mfa":    mfa,
+		"type":   "refresh",
+		"exp":    time.Now().Add(refreshTokenLifetime).Unix(),
 	})
-
-	// Подпись refresh token
-	refreshTokenString, err := refreshToken.SignedString(SECRET_KEY)
 	if err != nil {
 		return "", "", err
 	}
@@ -53,45 +73,79 @@ func GenerateAllTokens(email, firstName, lastName, userType, uid string) (string
 	return tokenString, refreshTokenString, nil
 }
 
-func UpdateAllTokens(signedToken string, signedRefreshToken string, userId string) {
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-
-	var updateObj primitive.D
-
-	updateObj = append(updateObj, bson.E{Key: "token", Value: signedToken})
-	updateObj = append(updateObj, bson.E{Key: "refresh_token", Value: signedRefreshToken})
-
+// UpdateAllTokens регистрирует выданный refresh token. Токены больше не хранятся
+// в документе пользователя: у каждого устройства своя сессия (см. CreateSession),
+// поэтому вход с нового устройства не затирает токены остальных.
+func UpdateAllTokens(ctx context.Context, signedToken string, signedRefreshToken string, userId string) error {
 	UpdatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	updateObj = append(updateObj, bson.E{Key: "updated_at", Value: UpdatedAt})
-
-	upsert := true
-	filter := bson.M{"user_id": userId}
-
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
-	}
 
 	_, err := userCollection.UpdateOne(
 		ctx,
-		filter,
-		bson.D{
-			{Key: "$set", Value: updateObj},
+		bson.M{"user_id": userId},
+		bson.M{
+			"$set":   bson.M{"updated_at": UpdatedAt},
+			"$unset": bson.M{"token": "", "refresh_token": ""},
 		},
-		&opt,
 	)
 
 	if err != nil {
-		log.Panic(err)
-		return
+		return err
 	}
-	return
+
+	return StoreRefreshToken(ctx, signedRefreshToken)
 }
 
 func ValidateToken(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return SECRET_KEY, nil
-	})
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refresh token и одноразовые токены нельзя использовать вместо токена доступа
+	if _, ok := claims["type"]; ok {
+		return nil, fmt.Errorf("Invalid token type")
+	}
+
+	if err := checkTokenRevoked(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// ValidateRefreshToken проверяет подпись и срок действия refresh token и что его сессия не отозвана
+func ValidateRefreshToken(tokenString string) (jwt.MapClaims, error) {
+	claims, err := parseRefreshToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkTokenRevoked(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// parseRefreshToken проверяет подпись и тип refresh token без проверки отзыва —
+// для только что выданных токенов, сессия которых ещё создаётся
+func parseRefreshToken(tokenString string) (jwt.MapClaims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims["type"] != "refresh" {
+		return nil, fmt.Errorf("Invalid token type")
+	}
+
+	return claims, nil
+}
+
+// parseToken проверяет подпись токена ключом из его kid (алгоритм должен совпадать
+// с алгоритмом ключа) и обязательный срок действия
+func parseToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, verificationKey)
 
 	if err != nil {
 		return nil, fmt.Errorf("Failed to parse token: %v", err)
@@ -101,16 +155,21 @@ func ValidateToken(tokenString string) (jwt.MapClaims, error) {
 		return nil, fmt.Errorf("Invalid token")
 	}
 
-	// Проверка времени истечения (exp)
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
 		return nil, fmt.Errorf("Failed to extract claims from token")
 	}
 
-	// expirationTime := time.Unix(int64(claims["exp"].(float64)), 0)
-	// if time.Now().After(expirationTime) {
-	// 	return nil, fmt.Errorf("Token has expired")
-	// }
+	// Проверка времени истечения (exp): jwt-go пропускает токены без exp
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("Token has no expiration time")
+	}
+
+	expirationTime := time.Unix(int64(exp), 0)
+	if !time.Now().Before(expirationTime) {
+		return nil, fmt.Errorf("Token has expired")
+	}
 
 	return claims, nil
 }
@@ -0,0 +1,78 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Message — письмо для отправки
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма пользователям
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var (
+	defaultMailer Mailer
+	defaultErr    error
+	once          sync.Once
+)
+
+// New создаёт Mailer для драйвера driver: "smtp" — отправка через SMTP-сервер,
+// "log" — письма пишутся в лог или в файл MAIL_LOG_FILE (для локальной разработки).
+// Драйвер выбирается явно, чтобы сервер без настроенной почты не терял письма молча.
+func New(driver string) (Mailer, error) {
+	switch driver {
+	case "smtp":
+		mailer := &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+		if mailer.Host == "" || mailer.From == "" {
+			return nil, fmt.Errorf("SMTP_HOST and MAIL_FROM are required for MAIL_DRIVER=smtp")
+		}
+		return mailer, nil
+	case "log":
+		return &LogMailer{Path: os.Getenv("MAIL_LOG_FILE")}, nil
+	case "":
+		return nil, fmt.Errorf("MAIL_DRIVER is not set: use smtp, or log for local development")
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q: use smtp or log", driver)
+	}
+}
+
+// Init выбирает Mailer по переменной окружения MAIL_DRIVER.
+// Вызывается при запуске сервера, чтобы ошибка настройки была видна сразу.
+func Init() error {
+	once.Do(func() {
+		defaultMailer, defaultErr = New(os.Getenv("MAIL_DRIVER"))
+	})
+	return defaultErr
+}
+
+// Default возвращает Mailer, выбранный Init. Если почта не настроена, Send возвращает ошибку настройки.
+func Default() Mailer {
+	if err := Init(); err != nil {
+		return unconfiguredMailer{err: err}
+	}
+	return defaultMailer
+}
+
+// unconfiguredMailer отвечает на каждую отправку ошибкой настройки
+type unconfiguredMailer struct {
+	err error
+}
+
+func (m unconfiguredMailer) Send(ctx context.Context, msg Message) error {
+	return m.err
+}
@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewRequiresExplicitDriver(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+	t.Setenv("MAIL_FROM", "")
+
+	for _, driver := range []string{"", "sendgrid", "smtp"} {
+		if _, err := New(driver); err == nil {
+			t.Fatalf("expected an error for MAIL_DRIVER=%q", driver)
+		}
+	}
+
+	if _, err := New("log"); err != nil {
+		t.Fatalf("log driver rejected: %v", err)
+	}
+}
+
+func TestLogMailerWritesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	mailer := &LogMailer{Path: path}
+
+	err := mailer.Send(context.Background(), Message{To: "player@example.com", Subject: "Hello", Body: "link"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "To: player@example.com") || !strings.Contains(string(data), "link") {
+		t.Fatalf("unexpected mail log: %s", data)
+	}
+}
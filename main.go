@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
+	"log"
 	"os"
 	"time"
 
+	controller "oiynlike/controllers"
 	"oiynlike/database"
+	helper "oiynlike/helpers"
+	"oiynlike/mailer"
 	routes "oiynlike/routes"
+	"oiynlike/scheduler"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -15,6 +21,55 @@ func main() {
 
 	database.ConnectToMongoDB()
 
+	// Подкоманды сервера, например: oiynlike create-admin --email admin@example.com
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Без явно выбранного MAIL_DRIVER письма подтверждения и сброса пароля не уйдут
+	if err := mailer.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Индексы по jti для проверки токенов; TTL-индексы удаляют истёкшие токены и сессии
+	if err := helper.EnsureTokenIndexes(context.Background()); err != nil {
+		log.Printf("Error creating token indexes: %v", err)
+	}
+
+	// Уникальные email и внешние входы пользователей
+	if err := controller.EnsureUserIndexes(context.Background()); err != nil {
+		log.Printf("Error creating user indexes: %v", err)
+	}
+
+	// Индексы 2dsphere для поиска поблизости
+	if err := controller.EnsureGeoIndexes(context.Background()); err != nil {
+		log.Printf("Error creating geo indexes: %v", err)
+	}
+
+	// Карточки со статусом "inactive" из прежних версий становятся "full"
+	if migrated, err := controller.MigrateInactiveGameCards(context.Background()); err != nil {
+		log.Printf("Error migrating gameCard statuses: %v", err)
+	} else if migrated > 0 {
+		log.Printf("Migrated %d inactive gameCards", migrated)
+	}
+
+	// Ротация ключей подписи JWT
+	helper.StartKeyRotation(time.Hour)
+
+	// Фоновые задачи выполняются только на ведущей реплике (блокировка в scheduler_locks)
+	jobs := scheduler.New()
+	jobs.Every("game-lifecycle", time.Minute, controller.RunGameCardLifecycle)
+	jobs.Every("game-reminders", 5*time.Minute, controller.SendGameReminders)
+	jobs.Every("series-occurrences", time.Hour, controller.GenerateAllSeriesOccurrences)
+	jobs.Every("expire-moderation", time.Hour, controller.ExpireStaleModerationCards)
+	jobs.Every("close-chats", 5*time.Minute, controller.CloseFinishedChats)
+	jobs.Every("purge-uploads", 24*time.Hour, controller.PurgeOldUploads)
+	jobs.Every("purge-table-reservations", 24*time.Hour, controller.PurgePastTableReservations)
+	jobs.Start()
+
 	port := os.Getenv("PORT")
 
 	if port == "" {
@@ -27,7 +82,7 @@ func main() {
 	// Использование CORS middleware
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
-	config.AllowMethods = []string{"POST", "GET", "PUT", "OPTIONS", "PATCH"}
+	config.AllowMethods = []string{"POST", "GET", "PUT", "OPTIONS", "PATCH", "DELETE"}
 	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "Accept", "User-Agent", "Cache-Control", "Pragma"}
 	config.ExposeHeaders = []string{"Content-Length"}
 	config.AllowCredentials = true
@@ -1,9 +1,12 @@
 package middleware
 
 import (
+	"context"
+	"log"
 	"net/http"
 	helper "oiynlike/helpers"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -33,48 +36,81 @@ func Authenticate() gin.HandlerFunc {
 		c.Set("last_name", claims["lastName"])
 		c.Set("uid", claims["uid"])
 		c.Set("user_type", claims["userType"])
+		c.Set("jti", claims["jti"])
+		c.Set("token_family", claims["family"])
+		c.Set("token_exp", claims["exp"])
+		c.Set("mfa", claims["mfa"] == true)
+
+		// Отмечаем активность сессии устройства
+		if family, ok := claims["family"].(string); ok {
+			var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+			if err := helper.TouchSession(ctx, family, c.Request.UserAgent(), c.ClientIP()); err != nil {
+				log.Printf("Error updating session: %v", err)
+			}
+			cancel()
+		}
+
 		c.Next()
 	}
 }
 
-func AuthAdmin() gin.HandlerFunc {
+// RequirePermission пропускает запрос, только если роль пользователя обладает
+// всеми перечисленными правами. Должен подключаться после Authenticate.
+func RequirePermission(permissions ...helper.Permission) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientToken := c.Request.Header.Get("Authorization")
-		if clientToken == "" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "No Authorization header provided"})
+		userType := c.GetString("user_type")
+		if !helper.IsValidRole(userType) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid user type"})
 			c.Abort()
 			return
 		}
 
-		if strings.HasPrefix(clientToken, "Bearer ") {
-			clientToken = strings.TrimPrefix(clientToken, "Bearer ")
+		for _, permission := range permissions {
+			if !helper.HasPermission(userType, permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized to access this resource"})
+				c.Abort()
+				return
+			}
 		}
 
-		claims, err := helper.ValidateToken(clientToken)
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail пропускает только пользователей с подтверждённой почтой.
+// Должен подключаться после Authenticate.
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		verified, err := helper.IsEmailVerified(ctx, c.GetString("uid"))
 		if err != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking email verification"})
 			c.Abort()
 			return
 		}
 
-		userType, ok := claims["userType"].(string)
-		if !ok {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid user type"})
+		if !verified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email is not verified"})
 			c.Abort()
 			return
 		}
 
-		if userType != "USER" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "User is not an admin"})
+		c.Next()
+	}
+}
+
+// RequireMFA не пускает пользователей ролей с обязательной 2FA (ADMIN, MODERATOR),
+// если вход не был подтверждён вторым фактором. Должен подключаться после Authenticate.
+func RequireMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if helper.RoleRequiresMFA(c.GetString("user_type")) && !c.GetBool("mfa") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for this role"})
 			c.Abort()
 			return
 		}
 
-		c.Set("email", claims["email"])
-		c.Set("first_name", claims["firstName"])
-		c.Set("last_name", claims["lastName"])
-		c.Set("uid", claims["uid"])
-		c.Set("user_type", claims["userType"])
 		c.Next()
 	}
 }
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ActionToken — одноразовый токен для подтверждения почты или сброса пароля
+type ActionToken struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	TokenID   string             `bson:"jti" json:"jti"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	Action    string             `bson:"action" json:"action"`
+	Used      bool               `bson:"used" json:"used"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -6,11 +6,21 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Table — стол антикафе, который можно забронировать под игру
+type Table struct {
+	ID    primitive.ObjectID `json:"id" bson:"id"`
+	Name  string             `json:"name" bson:"name"`
+	Seats int                `json:"seats" bson:"seats"`
+}
+
 type AnticafeModel struct {
 	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	OwnerID     string             `json:"ownerId" bson:"ownerId"`
 	Title       string             `json:"title" bson:"title" validate:"required"`
 	Rating      string             `json:"rating" bson:"rating"`
 	Address     string             `json:"address" bson:"address" validate:"required"`
+	City        string             `json:"city" bson:"city,omitempty"`
+	TimeZone    string             `json:"timeZone" bson:"timeZone,omitempty"`
 	OpeningTime string             `json:"openingTime" bson:"openingTime" validate:"required"`
 	ClosingTime string             `json:"closingTime" bson:"closingTime" validate:"required"`
 	PhoneNumber string             `json:"phoneNumber" bson:"phoneNumber" validate:"required"`
@@ -18,6 +28,8 @@ type AnticafeModel struct {
 	Photos      []string           `json:"photos" bson:"photos"`
 	Latitude    string             `json:"latitude" bson:"latitude"`
 	Longitude   string             `json:"longitude" bson:"longitude"`
+	Location    *GeoPoint          `json:"location,omitempty" bson:"location,omitempty"`
+	Tables      []Table            `json:"tables" bson:"tables,omitempty"`
 	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
 	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
 }
@@ -12,6 +12,8 @@ type Chat struct {
 	GameCardID primitive.ObjectID `bson:"gamecard_id" json:"gamecard_id"`
 	Members    []Sender           `bson:"members" json:"members"`
 	Messages   []Message          `bson:"messages" json:"messages"`
+	// Closed — чат закрыт после окончания или отмены игры, новые сообщения не принимаются
+	Closed bool `bson:"closed" json:"closed"`
 }
 
 type Message struct {
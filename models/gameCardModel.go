@@ -22,19 +22,75 @@ type MatchedPlayer struct {
 	City      string `json:"city,omitempty" bson:"city,omitempty"`
 }
 
+// Venue — сведения об антикафе, в котором проходит игра. Копируются в карточку
+// при привязке и обновляются вместе с антикафе.
+type Venue struct {
+	ID          primitive.ObjectID `json:"id" bson:"id"`
+	Title       string             `json:"title" bson:"title"`
+	Address     string             `json:"address" bson:"address"`
+	PhoneNumber string             `json:"phone_number,omitempty" bson:"phone_number,omitempty"`
+	OpeningTime string             `json:"opening_time" bson:"opening_time"`
+	ClosingTime string             `json:"closing_time" bson:"closing_time"`
+	TimeZone    string             `json:"time_zone" bson:"time_zone"`
+}
+
+// Статусы жизненного цикла игровой карточки. Допустимые переходы между ними
+// описаны в helpers.CheckGameCardTransition.
+const (
+	GameCardStatusDraft      = "draft"
+	GameCardStatusModeration = "moderation"
+	GameCardStatusActive     = "active"
+	GameCardStatusFull       = "full"
+	GameCardStatusStarted    = "started"
+	GameCardStatusCompleted  = "completed"
+	GameCardStatusCancelled  = "cancelled"
+	GameCardStatusRejected   = "rejected"
+)
+
+// StatusChange — запись истории переходов статуса карточки
+type StatusChange struct {
+	From      string    `json:"from" bson:"from"`
+	To        string    `json:"to" bson:"to"`
+	Actor     string    `json:"actor" bson:"actor"`
+	ChangedBy string    `json:"changed_by,omitempty" bson:"changed_by,omitempty"`
+	Reason    string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	ChangedAt time.Time `json:"changed_at" bson:"changed_at"`
+}
+
+// GameCard — игровая карточка. Повторения серии (см. GameSeries) ссылаются на неё
+// через SeriesID; SeriesOverride отмечает повторение, изменённое отдельно от серии.
+// DurationMinutes — альтернатива EndTime во входных данных, в базе не хранится.
+// Location — место проведения в формате GeoJSON для поиска игр поблизости.
+// AnticafeID — антикафе, в котором проходит игра; Venue — его краткие сведения, только для чтения.
+// TableID — забронированный стол антикафе (см. TableSchedule).
 type GameCard struct {
-	ID             primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	HostUser       HostUser           `json:"host_user" bson:"host_user"`
-	Title          string             `json:"title" bson:"title" validate:"required"`
-	Description    string             `json:"description" bson:"description" validate:"required"`
-	City           string             `json:"city" bson:"city" validate:"required"`
-	CoverURL       string             `json:"cover_url" bson:"cover_url"`
-	Category       string             `json:"category" bson:"category"`
-	MaxPlayers     int                `json:"max_players" bson:"max_players" validate:"gt=0"`
-	MinPlayers     int                `json:"min_players" bson:"min_players" validate:"gt=0"`
-	Status         string             `json:"status" bson:"status"`
-	MatchedPlayers []MatchedPlayer    `json:"matched_players" bson:"matched_players"`
-	CreatedAt      time.Time          `json:"created_at,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt      time.Time          `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
-	ScheduledTime  time.Time          `json:"scheduled_time,omitempty" bson:"scheduled_time,omitempty"`
+	ID              primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	HostUser        HostUser           `json:"host_user" bson:"host_user"`
+	Title           string             `json:"title" bson:"title" validate:"required"`
+	Description     string             `json:"description" bson:"description" validate:"required"`
+	City            string             `json:"city" bson:"city" validate:"required"`
+	Location        *GeoPoint          `json:"location,omitempty" bson:"location,omitempty"`
+	AnticafeID      primitive.ObjectID `json:"anticafe_id,omitempty" bson:"anticafe_id,omitempty"`
+	Venue           *Venue             `json:"venue,omitempty" bson:"venue,omitempty"`
+	TableID         primitive.ObjectID `json:"table_id,omitempty" bson:"table_id,omitempty"`
+	CoverURL        string             `json:"cover_url" bson:"cover_url"`
+	Category        string             `json:"category" bson:"category"`
+	MaxPlayers      int                `json:"max_players" bson:"max_players" validate:"gt=0,gtefield=MinPlayers"`
+	MinPlayers      int                `json:"min_players" bson:"min_players" validate:"gt=0"`
+	Status          string             `json:"status" bson:"status"`
+	StatusHistory   []StatusChange     `json:"status_history" bson:"status_history"`
+	JoinPolicy      string             `json:"join_policy" bson:"join_policy" validate:"omitempty,oneof=open approval"`
+	MatchedPlayers  []MatchedPlayer    `json:"matched_players" bson:"matched_players"`
+	Waitlist        []MatchedPlayer    `json:"waitlist" bson:"waitlist"`
+	CreatedAt       time.Time          `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt       time.Time          `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	ScheduledTime   time.Time          `json:"scheduled_time,omitempty" bson:"scheduled_time,omitempty" validate:"required"`
+	EndTime         time.Time          `json:"end_time,omitempty" bson:"end_time,omitempty"`
+	DurationMinutes int                `json:"duration_minutes,omitempty" bson:"-"`
+	TimeZone        string             `json:"time_zone" bson:"time_zone"`
+	SeriesID        primitive.ObjectID `json:"series_id,omitempty" bson:"series_id,omitempty"`
+	SeriesOverride  bool               `json:"series_override,omitempty" bson:"series_override,omitempty"`
+	ReminderSent    bool               `json:"-" bson:"reminder_sent,omitempty"`
+	ChatClosed      bool               `json:"-" bson:"chat_closed,omitempty"`
+	QuorumReachedAt time.Time          `json:"quorum_reached_at,omitempty" bson:"quorum_reached_at,omitempty"`
 }
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Статусы серии повторяющихся игр
+const (
+	SeriesStatusModeration = "moderation"
+	SeriesStatusActive     = "active"
+	SeriesStatusRejected   = "rejected"
+	SeriesStatusCancelled  = "cancelled"
+)
+
+// GameSeries — повторяющаяся игра. По правилу Recurrence серия заранее создаёт
+// обычные игровые карточки (повторения) со своим составом и чатом.
+type GameSeries struct {
+	ID          primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	HostUser    HostUser           `json:"host_user" bson:"host_user"`
+	Title       string             `json:"title" bson:"title" validate:"required"`
+	Description string             `json:"description" bson:"description" validate:"required"`
+	City        string             `json:"city" bson:"city" validate:"required"`
+	CoverURL    string             `json:"cover_url" bson:"cover_url"`
+	Category    string             `json:"category" bson:"category"`
+	MaxPlayers  int                `json:"max_players" bson:"max_players" validate:"gt=0,gtefield=MinPlayers"`
+	MinPlayers  int                `json:"min_players" bson:"min_players" validate:"gt=0"`
+	JoinPolicy  string             `json:"join_policy" bson:"join_policy" validate:"omitempty,oneof=open approval"`
+	// Recurrence — правило повторения RFC 5545, например "FREQ=WEEKLY;BYDAY=TH"
+	Recurrence string `json:"recurrence" bson:"recurrence" validate:"required"`
+	// StartTime — время первого повторения (DTSTART)
+	StartTime time.Time `json:"start_time" bson:"start_time" validate:"required"`
+	// TimeZone — пояс IANA, в котором повторения сохраняют местное время начала
+	TimeZone        string `json:"time_zone" bson:"time_zone"`
+	DurationMinutes int    `json:"duration_minutes" bson:"duration_minutes" validate:"gte=0"`
+	Status          string `json:"status" bson:"status"`
+	// GeneratedUntil — до какого момента повторения уже созданы
+	GeneratedUntil time.Time `json:"generated_until" bson:"generated_until"`
+	CreatedAt      time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// GeoPoint — точка в формате GeoJSON. Порядок координат как в GeoJSON: сначала долгота, потом широта.
+type GeoPoint struct {
+	Type        string    `json:"type" bson:"type"`
+	Coordinates []float64 `json:"coordinates" bson:"coordinates"`
+}
+
+// NewGeoPoint создаёт точку по широте и долготе
+func NewGeoPoint(lat, lng float64) *GeoPoint {
+	return &GeoPoint{Type: "Point", Coordinates: []float64{lng, lat}}
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Политики присоединения к игровой карточке
+const (
+	JoinPolicyOpen     = "open"
+	JoinPolicyApproval = "approval"
+)
+
+// Статусы заявки на участие
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestAccepted = "accepted"
+	JoinRequestRejected = "rejected"
+)
+
+// JoinRequest — заявка игрока на участие в карточке с политикой "approval",
+// которую принимает или отклоняет хост
+type JoinRequest struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	GameCardID primitive.ObjectID `bson:"gamecard_id" json:"gamecard_id"`
+	Player     MatchedPlayer      `bson:"player" json:"player"`
+	Status     string             `bson:"status" json:"status"`
+	DecidedBy  string             `bson:"decided_by,omitempty" json:"decided_by,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+}
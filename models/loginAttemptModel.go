@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// LoginAttempt — счётчик неудачных попыток входа для аккаунта или IP-адреса
+type LoginAttempt struct {
+	Key           string    `bson:"_id" json:"key"`
+	Failures      int       `bson:"failures" json:"failures"`
+	LastFailureAt time.Time `bson:"last_failure_at" json:"last_failure_at"`
+	NextAttemptAt time.Time `bson:"next_attempt_at" json:"next_attempt_at"`
+	LockedUntil   time.Time `bson:"locked_until" json:"locked_until"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Типы уведомлений внутри приложения
+const (
+	NotificationGameCardCancelled = "gamecard_cancelled"
+	NotificationGameCardReminder  = "gamecard_reminder"
+	NotificationGameCardQuorum    = "gamecard_quorum"
+)
+
+// Notification — уведомление пользователя внутри приложения
+type Notification struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	Type       string             `bson:"type" json:"type"`
+	Title      string             `bson:"title" json:"title"`
+	Body       string             `bson:"body" json:"body"`
+	GameCardID primitive.ObjectID `bson:"gamecard_id,omitempty" json:"gamecard_id,omitempty"`
+	Read       bool               `bson:"read" json:"read"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
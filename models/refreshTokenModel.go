@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken — запись о выданном refresh token. Все токены, полученные
+// цепочкой обменов от одного входа, принадлежат одному семейству (Family).
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	TokenID   string             `bson:"jti" json:"jti"`
+	Family    string             `bson:"family" json:"family"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	Used      bool               `bson:"used" json:"used"`
+	Revoked   bool               `bson:"revoked" json:"revoked"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RevokedToken — отозванный токен доступа. Запись нужна только до истечения
+// срока действия самого токена (ExpiresAt).
+type RevokedToken struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	TokenID   string             `bson:"jti" json:"jti"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RoleChange — запись аудита об изменении роли пользователя
+type RoleChange struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	OldRole   string             `bson:"old_role" json:"old_role"`
+	NewRole   string             `bson:"new_role" json:"new_role"`
+	ChangedBy string             `bson:"changed_by" json:"changed_by"`
+	Source    string             `bson:"source" json:"source"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
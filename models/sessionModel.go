@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session — вход пользователя с одного устройства. ID сессии совпадает с
+// семейством её refresh-токенов (claim family).
+type Session struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	UserAgent  string             `bson:"user_agent" json:"user_agent"`
+	IP         string             `bson:"ip" json:"ip"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	LastSeenAt time.Time          `bson:"last_seen_at" json:"last_seen_at"`
+	ExpiresAt  time.Time          `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	Current    bool               `bson:"-" json:"current"`
+}
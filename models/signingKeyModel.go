@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SigningKey — ключ подписи JWT. Новые токены подписываются, пока не наступил
+// RetiresAt; открытая часть публикуется в JWKS до ExpiresAt, чтобы выданные
+// ранее токены продолжали проверяться.
+type SigningKey struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	KeyID      string             `bson:"kid" json:"kid"`
+	Algorithm  string             `bson:"alg" json:"alg"`
+	PrivateKey string             `bson:"private_key" json:"-"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	RetiresAt  time.Time          `bson:"retires_at" json:"retires_at"`
+	ExpiresAt  time.Time          `bson:"expires_at" json:"expires_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TableSchedule — расписание одного стола антикафе. _id совпадает с ID стола, поэтому
+// бронь добавляется одним условным обновлением документа, и два пересекающихся
+// бронирования одного стола не могут пройти одновременно.
+type TableSchedule struct {
+	ID           primitive.ObjectID `bson:"_id" json:"table_id"`
+	AnticafeID   primitive.ObjectID `bson:"anticafe_id" json:"anticafe_id"`
+	Reservations []TableReservation `bson:"reservations" json:"reservations"`
+}
+
+// TableReservation — бронь стола под игровую карточку на время её проведения
+type TableReservation struct {
+	GameCardID primitive.ObjectID `bson:"gamecard_id" json:"gamecard_id"`
+	HostUserID string             `bson:"host_user_id" json:"host_user_id"`
+	StartTime  time.Time          `bson:"start_time" json:"start_time"`
+	EndTime    time.Time          `bson:"end_time" json:"end_time"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
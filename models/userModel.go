@@ -6,19 +6,41 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Identity — привязанный к аккаунту внешний вход (Google, Apple)
+type Identity struct {
+	Provider string    `bson:"provider" json:"provider"`
+	Subject  string    `bson:"subject" json:"subject"`
+	Email    string    `bson:"email" json:"email"`
+	LinkedAt time.Time `bson:"linked_at" json:"linked_at"`
+}
+
+// MFA — настройки двухфакторной аутентификации (TOTP)
+type MFA struct {
+	Enabled       bool      `bson:"enabled" json:"enabled"`
+	Secret        string    `bson:"secret,omitempty" json:"-"`
+	PendingSecret string    `bson:"pending_secret,omitempty" json:"-"`
+	RecoveryCodes []string  `bson:"recovery_codes,omitempty" json:"-"`
+	LastUsedStep  int64     `bson:"last_used_step,omitempty" json:"-"`
+	EnabledAt     time.Time `bson:"enabled_at,omitempty" json:"enabled_at,omitempty"`
+}
+
 type User struct {
-	ID           primitive.ObjectID `bson:"_id" json:"id"`
-	FirstName    string             `bson:"first_name" json:"first_name" validate:"required,omitempty"`
-	LastName     string             `bson:"last_name" json:"last_name" validate:"required,omitempty"`
-	Password     string             `bson:"password" json:"password" validate:"required,min=8"`
-	Email        string             `bson:"email" json:"email" validate:"email,omitempty,required"`
-	Token        string             `bson:"token" json:"token"`
-	UserType     string             `bson:"user_type" json:"user_type" validate:"required,eq=ADMIN|eq=USER"`
-	RefreshToken string             `bson:"refresh_token" json:"refresh_token"`
-	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
-	UserId       string             `bson:"user_id" json:"user_id"`
-	PhotoURL     string             `bson:"photo_url" json:"photo_url" validate:"omitempty"`
-	City         string             `bson:"city" json:"city" validate:"omitempty"`
-	AboutUser    string             `bson:"about_user" json:"about_user" validate:"omitempty"`
+	ID            primitive.ObjectID `bson:"_id" json:"id"`
+	FirstName     string             `bson:"first_name" json:"first_name" validate:"required,omitempty"`
+	LastName      string             `bson:"last_name" json:"last_name" validate:"required,omitempty"`
+	Password      string             `bson:"password" json:"password" validate:"required,min=8"`
+	Email         string             `bson:"email" json:"email" validate:"email,omitempty,required"`
+	Token         string             `bson:"token" json:"token"`
+	UserType      string             `bson:"user_type" json:"user_type" validate:"required,oneof=ADMIN MODERATOR VENUE_OWNER USER"`
+	RefreshToken  string             `bson:"refresh_token" json:"refresh_token"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+	UserId        string             `bson:"user_id" json:"user_id"`
+	PhotoURL      string             `bson:"photo_url" json:"photo_url" validate:"omitempty"`
+	City          string             `bson:"city" json:"city" validate:"omitempty"`
+	AboutUser     string             `bson:"about_user" json:"about_user" validate:"omitempty"`
+	TokenVersion  int                `bson:"token_version" json:"-"`
+	EmailVerified bool               `bson:"email_verified" json:"email_verified"`
+	Identities    []Identity         `bson:"identities,omitempty" json:"identities,omitempty"`
+	MFA           MFA                `bson:"mfa" json:"mfa"`
 }
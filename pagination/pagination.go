@@ -0,0 +1,267 @@
+package pagination
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// ErrInvalidCursor возвращается для повреждённого курсора или курсора другой сортировки
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SortField — поле, по которому список можно сортировать. Если задано Expr,
+// значение поля вычисляется в запросе (например, число свободных мест).
+type SortField struct {
+	Field string
+	Expr  interface{}
+}
+
+// Sorts — допустимые сортировки списка по их публичным именам (параметр sort_by)
+type Sorts map[string]SortField
+
+// Params — параметры страницы списка. Если задан Page, используется постраничный режим,
+// иначе — курсорный: следующая страница начинается после последнего элемента предыдущей,
+// поэтому новые записи не сдвигают и не дублируют выдачу.
+type Params struct {
+	Limit  int
+	Page   int
+	Cursor string
+	Sort   SortField
+	Desc   bool
+}
+
+// Meta — сведения о странице в ответе
+type Meta struct {
+	Current    int    `json:"current,omitempty"`
+	Total      int64  `json:"total"`
+	PageSize   int    `json:"page_size"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Page — страница списка в формате {"items": [...], "meta": {...}}
+type Page[T any] struct {
+	Items []T  `json:"items"`
+	Meta  Meta `json:"meta"`
+}
+
+// cursorToken — содержимое курсора: значение сортировки и _id последнего элемента
+type cursorToken struct {
+	Sort  string             `bson:"s"`
+	Desc  bool               `bson:"d"`
+	Value interface{}        `bson:"v"`
+	ID    primitive.ObjectID `bson:"id"`
+}
+
+// ParseParams читает limit, page, cursor, sort_by и sort (asc или desc) из запроса
+func ParseParams(c *gin.Context, sorts Sorts, defaultSort string, defaultDesc bool) (Params, error) {
+	params := Params{Limit: DefaultLimit, Cursor: c.Query("cursor"), Desc: defaultDesc}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return params, fmt.Errorf("limit must be a positive number")
+		}
+		if limit > MaxLimit {
+			limit = MaxLimit
+		}
+		params.Limit = limit
+	}
+
+	if value := c.Query("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page <= 0 {
+			return params, fmt.Errorf("page must be a positive number")
+		}
+		params.Page = page
+	}
+	if params.Page > 0 && params.Cursor != "" {
+		return params, fmt.Errorf("page and cursor cannot be used together")
+	}
+
+	sortBy := c.DefaultQuery("sort_by", defaultSort)
+	sort, ok := sorts[sortBy]
+	if !ok {
+		return params, fmt.Errorf("unsupported sort_by %q", sortBy)
+	}
+	params.Sort = sort
+
+	switch c.Query("sort") {
+	case "asc":
+		params.Desc = false
+	case "desc":
+		params.Desc = true
+	case "":
+	default:
+		return params, fmt.Errorf("sort must be asc or desc")
+	}
+
+	return params, nil
+}
+
+func (p Params) direction() int {
+	if p.Desc {
+		return -1
+	}
+	return 1
+}
+
+// keysetFilter отбирает элементы, идущие после курсора в порядке (поле сортировки, _id).
+// Документы без значения поля при сортировке по возрастанию идут первыми, по убыванию — последними.
+func (p Params) keysetFilter(token cursorToken) bson.M {
+	field := p.Sort.Field
+	idOp, valueOp := "$gt", "$gt"
+	if p.Desc {
+		idOp, valueOp = "$lt", "$lt"
+	}
+
+	if token.Value == nil {
+		if p.Desc {
+			return bson.M{field: nil, "_id": bson.M{idOp: token.ID}}
+		}
+		return bson.M{"$or": bson.A{
+			bson.M{field: nil, "_id": bson.M{idOp: token.ID}},
+			bson.M{field: bson.M{"$ne": nil}},
+		}}
+	}
+
+	conditions := bson.A{
+		bson.M{field: bson.M{valueOp: token.Value}},
+		bson.M{field: token.Value, "_id": bson.M{idOp: token.ID}},
+	}
+	if p.Desc {
+		conditions = append(conditions, bson.M{field: nil})
+	}
+	return bson.M{"$or": conditions}
+}
+
+func encodeCursor(token cursorToken) (string, error) {
+	data, err := bson.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(value string) (cursorToken, error) {
+	var token cursorToken
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return token, ErrInvalidCursor
+	}
+	if err := bson.Unmarshal(data, &token); err != nil {
+		return token, ErrInvalidCursor
+	}
+	if !isCursorValue(token.Value) {
+		return token, ErrInvalidCursor
+	}
+	return token, nil
+}
+
+// isCursorValue сообщает, что значение курсора — скаляр, время или ObjectID.
+// Документ или массив из подделанного курсора попал бы в keysetFilter как операторы запроса.
+func isCursorValue(value interface{}) bool {
+	switch value.(type) {
+	case nil, string, bool, int32, int64, float64,
+		primitive.DateTime, primitive.ObjectID, primitive.Decimal128, primitive.Timestamp:
+		return true
+	}
+	return false
+}
+
+// Find возвращает страницу документов коллекции, подходящих под filter.
+// Total — число всех подходящих документов, независимо от страницы.
+func Find[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, params Params) (Page[T], error) {
+	page := Page[T]{Items: []T{}, Meta: Meta{PageSize: params.Limit, Current: params.Page}}
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return page, err
+	}
+	page.Meta.Total = total
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: filter}}}
+	if params.Sort.Expr != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{params.Sort.Field: params.Sort.Expr}}})
+	}
+
+	if params.Cursor != "" {
+		token, err := decodeCursor(params.Cursor)
+		if err != nil {
+			return page, err
+		}
+		if token.Sort != params.Sort.Field || token.Desc != params.Desc {
+			return page, ErrInvalidCursor
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: params.keysetFilter(token)}})
+	}
+
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{
+		{Key: params.Sort.Field, Value: params.direction()},
+		{Key: "_id", Value: params.direction()},
+	}}})
+	if params.Page > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: int64(params.Page-1) * int64(params.Limit)}})
+	}
+	// Лишний элемент показывает, есть ли следующая страница
+	pipeline = append(pipeline, bson.D{{Key: "$limit", Value: params.Limit + 1}})
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return page, err
+	}
+	defer cursor.Close(ctx)
+
+	var raws []bson.Raw
+	if err := cursor.All(ctx, &raws); err != nil {
+		return page, err
+	}
+
+	if len(raws) > params.Limit {
+		page.Meta.HasMore = true
+		raws = raws[:params.Limit]
+	}
+
+	for _, raw := range raws {
+		var item T
+		if err := bson.Unmarshal(raw, &item); err != nil {
+			return page, err
+		}
+		page.Items = append(page.Items, item)
+	}
+
+	// Курсор на следующую страницу строится по последнему элементу
+	if page.Meta.HasMore && params.Page == 0 {
+		last := raws[len(raws)-1]
+		token := cursorToken{Sort: params.Sort.Field, Desc: params.Desc}
+		if id, ok := last.Lookup("_id").ObjectIDOK(); ok {
+			token.ID = id
+		}
+		if value, err := last.LookupErr(params.Sort.Field); err == nil {
+			var decoded interface{}
+			if err := value.Unmarshal(&decoded); err == nil {
+				token.Value = decoded
+			}
+		}
+		next, err := encodeCursor(token)
+		if err != nil {
+			return page, err
+		}
+		page.Meta.NextCursor = next
+	}
+
+	return page, nil
+}
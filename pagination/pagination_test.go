@@ -0,0 +1,65 @@
+package pagination
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDecodeCursorRoundTrip(t *testing.T) {
+	values := []interface{}{
+		nil,
+		"title",
+		int32(3),
+		int64(7),
+		1.5,
+		true,
+		primitive.NewDateTimeFromTime(time.Now()),
+		primitive.NewObjectID(),
+	}
+
+	for _, value := range values {
+		token := cursorToken{Sort: "created_at", Desc: true, Value: value, ID: primitive.NewObjectID()}
+		encoded, err := encodeCursor(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := decodeCursor(encoded)
+		if err != nil {
+			t.Fatalf("cursor with %T value rejected: %v", value, err)
+		}
+		if decoded != token {
+			t.Fatalf("expected %+v, got %+v", token, decoded)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsDocumentsAndArrays(t *testing.T) {
+	values := []interface{}{
+		bson.M{"$ne": nil},
+		bson.D{{Key: "$gt", Value: ""}},
+		bson.A{1, 2},
+		primitive.Regex{Pattern: ".*"},
+	}
+
+	for _, value := range values {
+		encoded, err := encodeCursor(cursorToken{Sort: "created_at", Value: value, ID: primitive.NewObjectID()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := decodeCursor(encoded); !errors.Is(err, ErrInvalidCursor) {
+			t.Fatalf("cursor with %T value: expected ErrInvalidCursor, got %v", value, err)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	for _, value := range []string{"not base64!", "AAAA"} {
+		if _, err := decodeCursor(value); !errors.Is(err, ErrInvalidCursor) {
+			t.Fatalf("cursor %q: expected ErrInvalidCursor, got %v", value, err)
+		}
+	}
+}
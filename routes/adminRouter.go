@@ -2,20 +2,30 @@ package routes
 
 import (
 	controller "oiynlike/controllers"
+	helper "oiynlike/helpers"
 	middleware "oiynlike/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func AdminRoutes(incomingRoutes *gin.Engine) {
-	authMiddleware := middleware.AuthAdmin()
+	admin := incomingRoutes.Group("api/admin", middleware.Authenticate(), middleware.RequireMFA())
 
-	incomingRoutes.Use(authMiddleware)
+	gameCards := admin.Group("gamecards", middleware.RequirePermission(helper.PermGameCardsModerate))
 	{
-		incomingRoutes.GET("api/admin/gamecards", controller.GetAllGameCards())
-		incomingRoutes.GET("api/admin/gamecards/:gameCardID", controller.GetGameCardByID())
-		incomingRoutes.POST("api/admin/gamecards/:gameCardID", controller.UpdateStatus())
+		gameCards.GET("", controller.GetAllGameCards())
+		gameCards.GET("/:gameCardID", controller.GetGameCardByID())
+		gameCards.POST("/:gameCardID", controller.UpdateStatus())
+	}
 
+	series := admin.Group("series", middleware.RequirePermission(helper.PermGameCardsModerate))
+	{
+		series.POST("/:seriesID", controller.ModerateSeries())
 	}
 
+	users := admin.Group("users")
+	{
+		users.PATCH("/:user_id/role", middleware.RequirePermission(helper.PermUsersManageRoles), controller.UpdateUserRole())
+		users.POST("/:user_id/unlock", middleware.RequirePermission(helper.PermUsersUnlock), controller.UnlockUser())
+	}
 }
@@ -2,19 +2,19 @@ package routes
 
 import (
 	controller "oiynlike/controllers"
+	helper "oiynlike/helpers"
 	middleware "oiynlike/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func AnticafeRoutes(incomingRoutes *gin.Engine) {
-	authMiddleware := middleware.AuthAdmin()
-
-	incomingRoutes.Use(authMiddleware)
+	anticafe := incomingRoutes.Group("api/anticafe", middleware.Authenticate(), middleware.RequireMFA())
 	{
-		incomingRoutes.POST("api/anticafe", controller.CreateAnticafe())
-		incomingRoutes.PATCH("api/anticafe", controller.UpdateAnticafe())
-		incomingRoutes.GET("api/anticafe", controller.GetAllAnticafe())
-		incomingRoutes.GET("api/anticafe/:anticafeID", controller.GetAnticafeByID())
+		anticafe.POST("", middleware.RequirePermission(helper.PermAnticafeManage), controller.CreateAnticafe())
+		anticafe.PATCH("/:id", middleware.RequirePermission(helper.PermAnticafeManage), controller.UpdateAnticafe())
+		anticafe.GET("", middleware.RequirePermission(helper.PermAnticafeRead), controller.GetAllAnticafe())
+		anticafe.GET("/:id", middleware.RequirePermission(helper.PermAnticafeRead), controller.GetAnticafeByID())
+		anticafe.GET("/:id/reservations", middleware.RequirePermission(helper.PermAnticafeManage), controller.GetAnticafeReservations())
 	}
 }
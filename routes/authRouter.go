@@ -9,6 +9,13 @@ import (
 func AuthRoutes(r *gin.Engine) {
 	r.POST("api/users/signup", controller.Signup())
 	r.POST("api/users/login", controller.Login())
+	r.POST("api/users/login/mfa", controller.LoginMFA())
+	r.POST("api/users/refresh", controller.RefreshToken())
+	r.POST("api/users/verify-email", controller.VerifyEmail())
+	r.POST("api/users/password/forgot", controller.ForgotPassword())
+	r.POST("api/users/password/reset", controller.ResetPassword())
+	r.POST("api/users/oidc/:provider", controller.OIDCLogin())
+	r.GET("/.well-known/jwks.json", controller.JWKS())
 	r.POST("api/upload_photo", controller.UploadPhoto())
 	SetupStaticRoutes(r)
 }
@@ -8,19 +8,33 @@ import (
 )
 
 func GameCardRoutes(incomingRoutes *gin.Engine) {
-	authMiddleware := middleware.Authenticate()
-
-	incomingRoutes.Use(authMiddleware)
+	api := incomingRoutes.Group("api", middleware.Authenticate())
 	{
-		// Все роуты, добавленные здесь, будут использовать authMiddleware
-		incomingRoutes.POST("api/gamecards", controller.CreateGameCard())
-		incomingRoutes.GET("api/gamecards", controller.GetActiveGameCards())
-		incomingRoutes.GET("api/user/gamecards", controller.GetUserGameCards())
-		incomingRoutes.PUT("api/join", controller.JoinGameCard())
-		incomingRoutes.PATCH("api/gamecards/:gameCardID", controller.UpdateGameCard())
-		incomingRoutes.GET("api/gamecards/filters", controller.GetFilterValues())
-	}
+		// Все роуты этой группы используют middleware.Authenticate
+		api.POST("/gamecards", middleware.RequireVerifiedEmail(), controller.CreateGameCard())
+		api.GET("/gamecards", controller.GetActiveGameCards())
+		api.GET("/user/gamecards", controller.GetUserGameCards())
+		api.PUT("/join", middleware.RequireVerifiedEmail(), controller.JoinGameCard())
+		api.PUT("/leave", controller.LeaveGameCard())
+		api.GET("/gamecards/:gameCardID", controller.GetGameCard())
+		api.PATCH("/gamecards/:gameCardID", controller.UpdateGameCard())
+		api.PATCH("/gamecards/:gameCardID/status", controller.ChangeGameCardStatus())
+		api.POST("/gamecards/:gameCardID/cancel", controller.CancelGameCard())
+		api.GET("/gamecards/filters", controller.GetFilterValues())
 
-	// incomingRoutes.GET("api/gamecards/:id", controller.GetGameCard())
+		// Бронирование стола антикафе, к которому привязана карточка
+		api.POST("/gamecards/:gameCardID/table", controller.ReserveTable())
+		api.POST("/gamecards/:gameCardID/table/release", controller.ReleaseTable())
 
+		// Повторяющиеся игры: серия создаёт карточки-повторения заранее
+		api.POST("/series", middleware.RequireVerifiedEmail(), controller.CreateSeries())
+		api.GET("/series/:seriesID", controller.GetSeries())
+		api.PATCH("/series/:seriesID", controller.UpdateSeries())
+		api.POST("/series/:seriesID/cancel", controller.CancelSeries())
+
+		// Заявки на участие в карточках с политикой "approval" рассматривает хост
+		api.GET("/gamecards/:gameCardID/requests", controller.GetJoinRequests())
+		api.POST("/gamecards/:gameCardID/requests/:requestID/accept", controller.AcceptJoinRequest())
+		api.POST("/gamecards/:gameCardID/requests/:requestID/reject", controller.RejectJoinRequest())
+	}
 }
@@ -8,13 +8,25 @@ import (
 )
 
 func UserRoutes(incomingRoutes *gin.Engine) {
-	authMiddleware := middleware.Authenticate()
-	incomingRoutes.Use(authMiddleware)
+	api := incomingRoutes.Group("api", middleware.Authenticate())
 
-	incomingRoutes.PATCH("api/user/profile", controller.UpdateProfile())
-	incomingRoutes.GET("api/user/profile", controller.GetProfile())
-	incomingRoutes.GET("api/user/chats", controller.GetUserChatsHandler())
-	incomingRoutes.DELETE("api/chat/:chat_id/leave_chat", controller.LeaveChatHandler())
-	incomingRoutes.POST("api/chat/:chat_id/message", controller.SendMessageHandler())
-	incomingRoutes.GET("api/users/:user_id", controller.GetUserData())
+	api.PATCH("/user/profile", controller.UpdateProfile())
+	api.GET("/user/profile", controller.GetProfile())
+	api.POST("/user/logout", controller.Logout())
+	api.POST("/user/logout/all", controller.LogoutAll())
+	api.POST("/user/mfa/totp/enroll", controller.EnrollTOTP())
+	api.POST("/user/mfa/totp/confirm", controller.ConfirmTOTP())
+	api.POST("/user/mfa/totp/disable", controller.DisableTOTP())
+	api.POST("/user/mfa/recovery-codes", controller.RegenerateRecoveryCodes())
+	api.GET("/user/sessions", controller.GetSessions())
+	api.DELETE("/user/sessions/:id", controller.RevokeSession())
+	api.POST("/user/verify-email/resend", controller.ResendVerificationEmail())
+	api.POST("/user/identities/:provider", controller.LinkIdentity())
+	api.DELETE("/user/identities/:provider", controller.UnlinkIdentity())
+	api.GET("/user/notifications", controller.GetNotifications())
+	api.POST("/user/notifications/:id/read", controller.MarkNotificationRead())
+	api.GET("/user/chats", controller.GetUserChatsHandler())
+	api.DELETE("/chat/:chat_id/leave_chat", controller.LeaveChatHandler())
+	api.POST("/chat/:chat_id/message", controller.SendMessageHandler())
+	api.GET("/users/:user_id", controller.GetUserData())
 }
@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LeaderLock — документ-блокировка в MongoDB, по которой реплики выбирают ведущую.
+// Ведущая реплика продлевает блокировку; если она перестала это делать, по истечении
+// TTL блокировку забирает другая реплика.
+type LeaderLock struct {
+	Collection *mongo.Collection
+	Name       string
+	Owner      string
+	TTL        time.Duration
+}
+
+// NewLeaderLock создаёт блокировку name с уникальным для процесса владельцем
+func NewLeaderLock(collection *mongo.Collection, name string, ttl time.Duration) *LeaderLock {
+	host, _ := os.Hostname()
+	return &LeaderLock{
+		Collection: collection,
+		Name:       name,
+		Owner:      fmt.Sprintf("%s-%d-%s", host, os.Getpid(), primitive.NewObjectID().Hex()),
+		TTL:        ttl,
+	}
+}
+
+// Acquire захватывает или продлевает блокировку. Возвращает false, если её держит другая реплика.
+func (l *LeaderLock) Acquire(ctx context.Context) (bool, error) {
+	now := time.Now()
+	filter := bson.M{
+		"_id": l.Name,
+		"$or": bson.A{
+			bson.M{"owner": l.Owner},
+			bson.M{"expires_at": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{
+		"owner":       l.Owner,
+		"expires_at":  now.Add(l.TTL),
+		"acquired_at": now,
+	}}
+
+	_, err := l.Collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// Документ есть, но блокировку держит другая реплика
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error acquiring leader lock: %v", err)
+	}
+	return true, nil
+}
+
+// Release отпускает блокировку, если она принадлежит этой реплике
+func (l *LeaderLock) Release(ctx context.Context) error {
+	_, err := l.Collection.DeleteOne(ctx, bson.M{"_id": l.Name, "owner": l.Owner})
+	return err
+}
@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"oiynlike/database"
+)
+
+// JobFunc — периодическая задача. Задачи должны быть идемпотентными: после смены
+// ведущей реплики задача может выполниться повторно раньше своего интервала.
+type JobFunc func(ctx context.Context) error
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      JobFunc
+	nextRun  time.Time
+}
+
+// Scheduler выполняет зарегистрированные задачи только на ведущей реплике
+type Scheduler struct {
+	lock *LeaderLock
+	// Tick — как часто проверяются блокировка и сроки задач
+	Tick time.Duration
+	// Timeout — ограничение времени одного запуска задачи. Блокировка продлевается
+	// перед каждой задачей, поэтому её TTL должен быть больше Timeout.
+	Timeout time.Duration
+
+	mu   sync.Mutex
+	jobs []*job
+}
+
+// New создаёт планировщик с блокировкой в коллекции scheduler_locks
+func New() *Scheduler {
+	return &Scheduler{
+		lock:    NewLeaderLock(database.OpenCollection("scheduler_locks"), "scheduler", 3*time.Minute),
+		Tick:    15 * time.Second,
+		Timeout: 100 * time.Second,
+	}
+}
+
+// Every регистрирует задачу name, выполняемую раз в interval
+func (s *Scheduler) Every(name string, interval time.Duration, run JobFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs = append(s.jobs, &job{name: name, interval: interval, run: run})
+}
+
+// Start запускает планировщик в фоне
+func (s *Scheduler) Start() {
+	go func() {
+		leader := false
+		for {
+			s.tick(&leader)
+			time.Sleep(s.Tick)
+		}
+	}()
+}
+
+func (s *Scheduler) tick(leader *bool) {
+	if !s.renew(leader) {
+		return
+	}
+
+	s.mu.Lock()
+	jobs := append([]*job(nil), s.jobs...)
+	s.mu.Unlock()
+
+	first := true
+	for _, j := range jobs {
+		now := time.Now()
+		if now.Before(j.nextRun) {
+			continue
+		}
+		// Задачи вместе могут идти дольше TTL блокировки: продлеваем её перед каждой,
+		// а если её успела забрать другая реплика, оставшиеся задачи выполнит она
+		if !first && !s.renew(leader) {
+			return
+		}
+		first = false
+
+		j.nextRun = now.Add(j.interval)
+		s.runJob(j)
+	}
+}
+
+// renew захватывает или продлевает блокировку и сообщает, ведущая ли эта реплика
+func (s *Scheduler) renew(leader *bool) bool {
+	var ctx, cancel = context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+
+	acquired, err := s.lock.Acquire(ctx)
+	if err != nil {
+		log.Printf("Scheduler: %v", err)
+		return false
+	}
+	if acquired != *leader {
+		if acquired {
+			log.Printf("Scheduler: became leader (%s)", s.lock.Owner)
+		} else {
+			log.Printf("Scheduler: lost leadership (%s)", s.lock.Owner)
+		}
+		*leader = acquired
+	}
+	return acquired
+}
+
+func (s *Scheduler) runJob(j *job) {
+	var ctx, cancel = context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Scheduler: job %s panicked: %v", j.name, r)
+		}
+	}()
+
+	if err := j.run(ctx); err != nil {
+		log.Printf("Scheduler: job %s failed: %v", j.name, err)
+	}
+}
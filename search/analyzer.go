@@ -0,0 +1,147 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// minStemLength — длина основы, короче которой окончания не отрезаются
+const minStemLength = 3
+
+// kazakhLetters — буквы, которые есть в казахском алфавите, но не в русском
+const kazakhLetters = "әғқңөұүһі"
+
+var englishSuffixes = []string{"ingly", "edly", "ings", "ing", "ies", "ed", "ly", "s"}
+
+var russianSuffixes = sortByLength([]string{
+	"иями", "ями", "ами", "ого", "его", "ому", "ему", "ыми", "ими", "ться", "тся",
+	"ать", "ять", "ить", "еть", "ешь", "ишь", "ет", "ит", "ут", "ют", "ат", "ят",
+	"ой", "ей", "ий", "ый", "ая", "яя", "ое", "ее", "ые", "ие", "ую", "юю",
+	"ов", "ев", "ам", "ям", "ах", "ях", "ом", "ем", "ию", "ия", "ии",
+	"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+})
+
+// kazakhSuffixes — падежные, притяжательные окончания и окончания множественного числа.
+// Казахский язык агглютинативный, поэтому окончания отрезаются по одному, пока находятся.
+var kazakhSuffixes = sortByLength([]string{
+	"ның", "нің", "дың", "дің", "тың", "тің",
+	"ға", "ге", "қа", "ке", "на", "не", "а", "е",
+	"да", "де", "та", "те", "нда", "нде",
+	"дан", "ден", "тан", "тен", "нан", "нен",
+	"ны", "ні", "ды", "ді", "ты", "ті",
+	"мен", "бен", "пен",
+	"ым", "ім", "ың", "ің", "ы", "і", "сы", "сі", "мыз", "міз",
+	"лар", "лер", "дар", "дер", "тар", "тер",
+})
+
+// sortByLength упорядочивает окончания от длинных к коротким, чтобы отрезалось самое длинное
+func sortByLength(suffixes []string) []string {
+	sort.SliceStable(suffixes, func(i, j int) bool {
+		return len([]rune(suffixes[i])) > len([]rune(suffixes[j]))
+	})
+	return suffixes
+}
+
+// Analyze разбивает текст документа на слова и приводит их к основам, чтобы разные
+// формы слова ("настольные игры", "настольная игра") совпадали при поиске.
+// Латинские слова считаются английскими. Кириллица считается казахской, если в тексте
+// есть казахские буквы, иначе — русской.
+func Analyze(text string) []string {
+	kazakh := strings.ContainsAny(strings.ToLower(text), kazakhLetters)
+
+	var terms []string
+	for _, word := range words(text) {
+		switch {
+		case !isCyrillic(word):
+			terms = append(terms, stemEnglish(word))
+		case kazakh:
+			terms = append(terms, stemKazakh(word))
+		default:
+			terms = append(terms, stripSuffix(word, russianSuffixes))
+		}
+	}
+	return terms
+}
+
+// AnalyzeQuery приводит слова запроса к основам так же, как Analyze. По короткому запросу
+// без казахских букв язык не определить, поэтому для кириллицы добавляются обе основы.
+func AnalyzeQuery(query string) []string {
+	if strings.ContainsAny(strings.ToLower(query), kazakhLetters) {
+		return Analyze(query)
+	}
+
+	var terms []string
+	seen := map[string]bool{}
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	for _, word := range words(query) {
+		if !isCyrillic(word) {
+			add(stemEnglish(word))
+			continue
+		}
+		add(stripSuffix(word, russianSuffixes))
+		add(stemKazakh(word))
+	}
+	return terms
+}
+
+// words разбивает текст на слова в нижнем регистре, отбрасывая однобуквенные
+func words(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	result := make([]string, 0, len(fields))
+	for _, word := range fields {
+		word = strings.ReplaceAll(word, "ё", "е")
+		if len([]rune(word)) < 2 {
+			continue
+		}
+		result = append(result, word)
+	}
+	return result
+}
+
+func stemEnglish(word string) string {
+	stemmed := stripSuffix(word, englishSuffixes)
+	if strings.HasSuffix(word, "ies") && stemmed != word {
+		return stemmed + "y"
+	}
+	return stemmed
+}
+
+// stemKazakh отрезает окончания, пока основа не перестанет меняться
+func stemKazakh(word string) string {
+	for {
+		stemmed := stripSuffix(word, kazakhSuffixes)
+		if stemmed == word {
+			return word
+		}
+		word = stemmed
+	}
+}
+
+// stripSuffix отрезает самое длинное подходящее окончание, если остаётся достаточно длинная основа
+func stripSuffix(word string, suffixes []string) string {
+	runes := len([]rune(word))
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(word, suffix) && runes-len([]rune(suffix)) >= minStemLength {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
+
+func isCyrillic(word string) bool {
+	for _, r := range word {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return true
+		}
+	}
+	return false
+}
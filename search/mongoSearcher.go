@@ -0,0 +1,156 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"oiynlike/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// termsField — поле документа с основами слов индексируемых полей
+const termsField = "search_terms"
+
+// MongoSearcher ищет по текстовому индексу MongoDB. Встроенные стеммеры MongoDB не
+// поддерживают казахский язык, поэтому основы слов строит Analyze, а индекс создаётся
+// без языка и только сравнивает готовые основы.
+type MongoSearcher struct {
+	mu      sync.Mutex
+	ensured map[Index]bool
+}
+
+// collection возвращает коллекцию индекса, при первом обращении создавая текстовый индекс
+func (s *MongoSearcher) collection(ctx context.Context, index Index) (*mongo.Collection, error) {
+	fields, ok := indexFields[index]
+	if !ok {
+		return nil, fmt.Errorf("unknown search index %q", index)
+	}
+
+	collection := database.OpenCollection(string(index))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ensured[index] {
+		return collection, nil
+	}
+
+	keys := bson.D{}
+	weights := bson.M{}
+	for _, field := range fields {
+		keys = append(keys, bson.E{Key: termsField + "." + field.Name, Value: "text"})
+		weights[termsField+"."+field.Name] = field.Weight
+	}
+	model := mongo.IndexModel{
+		Keys: keys,
+		Options: options.Index().
+			SetName("search_text").
+			SetWeights(weights).
+			SetDefaultLanguage("none"),
+	}
+	if _, err := collection.Indexes().CreateOne(ctx, model); err != nil {
+		return nil, fmt.Errorf("error creating search index: %v", err)
+	}
+
+	if s.ensured == nil {
+		s.ensured = map[Index]bool{}
+	}
+	s.ensured[index] = true
+	return collection, nil
+}
+
+func (s *MongoSearcher) Index(ctx context.Context, index Index, doc Document) error {
+	collection, err := s.collection(ctx, index)
+	if err != nil {
+		return err
+	}
+
+	terms := bson.M{}
+	for _, field := range indexFields[index] {
+		terms[field.Name] = strings.Join(Analyze(doc.Fields[field.Name]), " ")
+	}
+
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": doc.ID}, bson.M{"$set": bson.M{termsField: terms}})
+	if err != nil {
+		return fmt.Errorf("error indexing document: %v", err)
+	}
+	return nil
+}
+
+// textFilter добавляет к условиям списка filter текстовый запрос по основам слов query.
+// Возвращает false, если в запросе нет слов.
+func textFilter(query string, filter bson.M) (bson.M, bool) {
+	terms := AnalyzeQuery(query)
+	if len(terms) == 0 {
+		return nil, false
+	}
+
+	result := bson.M{"$text": bson.M{"$search": strings.Join(terms, " "), "$language": "none"}}
+	for key, value := range filter {
+		result[key] = value
+	}
+	return result, true
+}
+
+func (s *MongoSearcher) Search(ctx context.Context, index Index, query string, filter bson.M, skip int, limit int) ([]Hit, error) {
+	textQuery, ok := textFilter(query, filter)
+	if !ok {
+		return []Hit{}, nil
+	}
+
+	collection, err := s.collection(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+
+	// При равной релевантности порядок задаёт _id, чтобы страницы не пересекались
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 1, "score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, textQuery, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error searching: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Score float64            `bson:"score"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, 0, len(results))
+	for _, result := range results {
+		hits = append(hits, Hit{ID: result.ID, Score: result.Score})
+	}
+	return hits, nil
+}
+
+func (s *MongoSearcher) Count(ctx context.Context, index Index, query string, filter bson.M) (int64, error) {
+	textQuery, ok := textFilter(query, filter)
+	if !ok {
+		return 0, nil
+	}
+
+	collection, err := s.collection(ctx, index)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := collection.CountDocuments(ctx, textQuery)
+	if err != nil {
+		return 0, fmt.Errorf("error counting search results: %v", err)
+	}
+	return count, nil
+}
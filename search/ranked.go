@@ -0,0 +1,79 @@
+package search
+
+import (
+	"context"
+
+	"oiynlike/pagination"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// FindRanked ищет query в индексе index и возвращает страницу документов коллекции,
+// подходящих под filter, в порядке релевантности. Порядок задаёт поиск, поэтому выдача
+// постраничная: курсор и sort_by не поддерживаются.
+func FindRanked[T any](ctx context.Context, searcher Searcher, index Index, collection *mongo.Collection, query string, filter bson.M, params pagination.Params) (pagination.Page[T], error) {
+	current := params.Page
+	if current == 0 {
+		current = 1
+	}
+	page := pagination.Page[T]{Items: []T{}, Meta: pagination.Meta{PageSize: params.Limit, Current: current}}
+
+	if params.Cursor != "" {
+		return page, pagination.ErrInvalidCursor
+	}
+
+	total, err := searcher.Count(ctx, index, query, filter)
+	if err != nil {
+		return page, err
+	}
+	page.Meta.Total = total
+
+	// Лишний результат показывает, есть ли следующая страница
+	hits, err := searcher.Search(ctx, index, query, filter, (current-1)*params.Limit, params.Limit+1)
+	if err != nil {
+		return page, err
+	}
+	if len(hits) > params.Limit {
+		page.Meta.HasMore = true
+		hits = hits[:params.Limit]
+	}
+	if len(hits) == 0 {
+		return page, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return page, err
+	}
+	var raws []bson.Raw
+	if err := cursor.All(ctx, &raws); err != nil {
+		return page, err
+	}
+
+	byID := make(map[primitive.ObjectID]bson.Raw, len(raws))
+	for _, raw := range raws {
+		if id, ok := raw.Lookup("_id").ObjectIDOK(); ok {
+			byID[id] = raw
+		}
+	}
+	for _, id := range ids {
+		raw, ok := byID[id]
+		if !ok {
+			continue
+		}
+		var item T
+		if err := bson.Unmarshal(raw, &item); err != nil {
+			return page, err
+		}
+		page.Items = append(page.Items, item)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,71 @@
+package search
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Index — набор документов, по которому выполняется поиск
+type Index string
+
+const (
+	IndexGameCards Index = "gamecards"
+	IndexAnticafes Index = "anticafe"
+)
+
+// indexField — индексируемое поле и его вес в ранжировании
+type indexField struct {
+	Name   string
+	Weight int
+}
+
+// indexFields — поля индексов: совпадение в названии важнее совпадения в описании
+var indexFields = map[Index][]indexField{
+	IndexGameCards: {{"title", 10}, {"description", 2}},
+	IndexAnticafes: {{"title", 10}, {"address", 5}, {"description", 2}},
+}
+
+// Document — документ для индексации: текст полей индекса по их именам
+type Document struct {
+	ID     primitive.ObjectID
+	Fields map[string]string
+}
+
+// Hit — найденный документ и его релевантность запросу
+type Hit struct {
+	ID    primitive.ObjectID
+	Score float64
+}
+
+// Searcher индексирует документы и ищет их по тексту запроса.
+// Реализация на текстовом индексе MongoDB может быть заменена отдельным поисковым движком.
+type Searcher interface {
+	// Index добавляет документ в индекс или обновляет его
+	Index(ctx context.Context, index Index, doc Document) error
+	// Search возвращает документы индекса, подходящие под запрос и условия списка filter,
+	// по убыванию релевантности: не больше limit, пропустив первые skip
+	Search(ctx context.Context, index Index, query string, filter bson.M, skip int, limit int) ([]Hit, error)
+	// Count возвращает число документов, подходящих под запрос и filter
+	Count(ctx context.Context, index Index, query string, filter bson.M) (int64, error)
+}
+
+var (
+	defaultSearcher Searcher
+	once            sync.Once
+)
+
+// Default возвращает Searcher, выбранный переменной окружения SEARCH_DRIVER.
+// Пока поддерживается только текстовый индекс MongoDB ("mongo", по умолчанию).
+func Default() Searcher {
+	once.Do(func() {
+		switch os.Getenv("SEARCH_DRIVER") {
+		default:
+			defaultSearcher = &MongoSearcher{}
+		}
+	})
+	return defaultSearcher
+}